@@ -1,11 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	termbox "github.com/nsf/termbox-go"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 	"unicode"
@@ -17,90 +20,154 @@ var (
 
 func DrawGame(game *GameState) {
 	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
-	termw, termh := termbox.Size()
 
-	width := game.board.width*2 + 2 + 10
-	left := (termw - width) / 2
-
-	height := game.board.height + 2
-	top := (termh - height) / 2
-	bottom := top + height
+	v := game.Viewport()
+	bottom := v.top + v.rows + 2
 
 	for x := 0; x < game.board.width; x += 1 {
 		for y := 0; y < game.board.height; y += 1 {
-			printC(left+2*x, top+y, termbox.ColorBlue, "· ")
+			v.Print(Position{x, y}, theme.board, "· ")
+		}
+	}
+
+	if show_tracks {
+		for _, p := range game.planes {
+			if !p.IsFlying() {
+				continue
+			}
+
+			ticks := TRACK_TICKS
+			if p == game.selected {
+				ticks = TRACK_TICKS_SELECTED
+			}
+			track := p.Predict(game.board, ticks)
+
+			color := theme.track
+			if track.warning {
+				color = theme.track_warning
+			}
+			for _, pos := range track.positions {
+				v.Print(pos, color, "• ")
+			}
 		}
 	}
 
+	keys := game.board.FixKeys()
 	for _, ep := range game.board.entrypoints {
-		print(left+ep.Position.x*2, top+ep.Position.y, string(ep.sign))
+		if key, ok := keys[ep.name]; ok {
+			v.Print(ep.Position, theme.fix, string(key))
+		} else {
+			v.Print(ep.Position, theme.fix, ep.name)
+		}
 	}
 
 	for _, navaid := range game.board.navaids {
-		print(left+navaid.x*2, top+navaid.y, "*")
+		if v.cell == 1 && navaid.name != 0 {
+			v.Print(navaid.Position, theme.fix, string(navaid.name))
+		} else {
+			v.Print(navaid.Position, theme.fix, navaid.String())
+		}
 	}
 
 	for _, nf := range game.board.nofly {
-		printC(left+nf.x*2, top+nf.y, termbox.ColorBlue, "XX")
+		v.Print(nf, theme.nofly, "XX")
 	}
 
-	col := left + game.board.width*2 + 2
-	row := top
+	// legend of the declared fixes below the strips
+	col := v.left + v.cols*v.cell + 2
+	legend := FixLegend(game.board, STRIP_WIDTH)
+	legend_top := bottom - len(legend)
+	if !v.Fits(game.board) {
+		m := v.Minimap(game.board)
+		legend_top = m.top - 1 - len(legend)
+		DrawMinimap(game, v, m)
+	}
+	DrawStrips(game, col, v.top, legend_top)
+	for n, line := range legend {
+		printC(col, legend_top+n, theme.fix, line)
+	}
 
 	printPlane := func(plane *Plane, color termbox.Attribute) {
 		if plane != nil && plane.IsFlying() {
-			printC(left+plane.Position.x*2, top+plane.Position.y,
-				color, plane.Marker())
+			v.PrintMarker(plane.Position, color, plane.Marker())
 		}
 	}
 
 	for _, p := range game.planes {
-		if row >= bottom {
-			row = top
-			col += 10
-		}
+		printPlane(p, theme.PlaneColor(p))
+	}
 
-		if p.IsVisible() {
-			print(col, row, p.Flightplan(), " *")
-			row += 1
-		} else if p.IsActive() {
-			print(col, row, p.Flightplan())
-			row += 1
+	// always show last commanded and selected plane on top
+	for _, p := range []*Plane{game.ci.last_commanded_plane, game.selected} {
+		if p != nil {
+			printPlane(p, theme.PlaneColor(p)|theme.selected)
 		}
-
-		printPlane(p, termbox.ColorDefault)
 	}
 
-	// always show last commanded plane on top
-	printPlane(game.ci.last_commanded_plane, termbox.ColorDefault)
-
-	x := left
-	y := top + game.board.height + 1
+	x := v.left
+	y := v.top + v.rows + 1
 
 	x = print(x, y, game.clock.String(), "  ")
+	if game.paused && game.end_reason == nil {
+		x = printC(x, y, termbox.AttrReverse, "PAUSED")
+		x = print(x, y, "  ")
+	}
 	if game.end_reason != nil {
 		x0 := print(x, y+0, "-- ", game.end_reason.message, " --")
 
 		for _, p := range game.end_reason.planes {
-			printPlane(p, termbox.ColorRed)
+			printPlane(p, theme.conflict)
 			x0 = print(x0, y, " ", p.Marker())
 		}
-		print(x, y+1, "(Press Esc to quit / R to restart same game)")
+		if game.transcript_file != "" {
+			print(x, y+1, "(Transcript: ", game.transcript_file, ")")
+		} else if game.transcript_error != nil {
+			print(x, y+1, "(Transcript not saved: ", game.transcript_error.Error(), " / T to retry)")
+		} else {
+			print(x, y+1, "(Press Esc to quit / R to restart same game / T to save transcript)")
+		}
+		termbox.HideCursor()
 	} else {
 		print(x, y, game.ci.StatusLine())
+		if game.ci.buf != "" {
+			termbox.SetCursor(x+game.ci.cursor, y)
+		} else {
+			termbox.HideCursor()
+		}
+
+		// emergencies before mission events
+		message, color := game.EventMessage(), theme.fix
+		for _, p := range game.planes {
+			if p.HasEmergency() {
+				message, color = p.EmergencyMessage(), theme.emergency
+				break
+			}
+		}
+		printC(x, y+1, color, message)
+	}
+
+	if game.tutor != nil {
+		DrawTutor(game, v)
 	}
 }
 
-func RunGame(rules *GameRules, board *Board, diff *Difficulty, seed int64) {
+// play games made by new_game until Esc. Returns the last game.
+func RunGame(new_game func() *GameState) *GameState {
 	tick_time := time.Duration(SECONDS_PER_TICK) * time.Second
 	timer := time.NewTimer(tick_time)
 	defer timer.Stop()
 
-	game := NewGame(rules, board, diff, seed)
+	game := new_game()
+	defer termbox.HideCursor()
 
 	var help_visible bool = false
 	var help_screen uint = 0
 	var planes_visible bool = false
+	var delayed_visible bool = false
+	var delayed_selected int = 0
+	var history_visible bool = false
+	var transcript_visible bool = false
+	var transcript_scroll int = 0
 
 	for {
 		DrawGame(game)
@@ -110,11 +177,22 @@ func RunGame(rules *GameRules, board *Board, diff *Difficulty, seed int64) {
 		if planes_visible {
 			planes_visible = DrawPlanes(game)
 		}
+		if delayed_visible {
+			delayed_visible = DrawDelayed(game, delayed_selected)
+		}
+		if history_visible {
+			history_visible = DrawHistory(game)
+		}
+		if transcript_visible {
+			transcript_visible = DrawTranscript(game, &transcript_scroll)
+		}
 		termbox.Flush()
 
 		select {
 		case <-timer.C:
-			game.Tick()
+			if !game.paused {
+				game.Tick()
+			}
 			timer.Reset(tick_time)
 
 		case ev := <-events:
@@ -125,36 +203,103 @@ func RunGame(rules *GameRules, board *Board, diff *Difficulty, seed int64) {
 					DialogKeys(ev, &help_visible, &help_screen)
 				case planes_visible:
 					DialogKeys(ev, &planes_visible, nil)
+				case delayed_visible:
+					DelayedKeys(ev, game, &delayed_visible, &delayed_selected)
+				case history_visible:
+					DialogKeys(ev, &history_visible, nil)
+				case transcript_visible:
+					TranscriptKeys(ev, &transcript_visible, &transcript_scroll)
 				default:
-					switch ev.Ch {
-					case 0:
-						switch ev.Key {
-						case termbox.KeyEsc:
-							return // end game
-						case termbox.KeySpace,
-							termbox.KeyEnter:
-							game.ci.Clear()
-						case termbox.KeyBackspace, termbox.KeyBackspace2:
-							game.ci.Clear()
-						case termbox.KeyTab:
-							planes_visible = true
+					switch key_bindings.Action(ev) {
+					case ActionQuit:
+						return game // end game
+					case ActionClear:
+						game.ci.Clear()
+					case ActionSubmit:
+						game.Submit()
+					case ActionAdvance:
+						if game.tutor.Waiting() {
+							// the lesson waits for a command
+							break
 						}
-					case ',':
 						game.Tick()
 
 						if game.rules.skip_to_next_tick {
 							timer.Reset(tick_time)
 						}
-					case '?':
+					case ActionPause:
+						game.paused = !game.paused
+					case ActionHelp:
 						help_visible = true
-					case 'R', 'r':
-						if game.end_reason != nil {
-							game = NewGame(rules, board, diff, seed)
-						} else {
-							game.KeyPressed(unicode.ToUpper(ev.Ch))
-						}
+					case ActionPlanes:
+						planes_visible = true
+					case ActionDelayed:
+						delayed_visible = true
+					case ActionHistory:
+						history_visible = true
+					case ActionTranscript:
+						transcript_visible = true
+						transcript_scroll = 0
+					case ActionSelectPrev:
+						game.SelectStrip(-1)
+					case ActionSelectNext:
+						game.SelectStrip(1)
+					case ActionSortStrips:
+						game.strips_by_arrival = !game.strips_by_arrival
+					case ActionTracks:
+						show_tracks = !show_tracks
+					case ActionScrollLeft:
+						game.Scroll(-1, 0)
+					case ActionScrollRight:
+						game.Scroll(1, 0)
+					case ActionScrollUp:
+						game.Scroll(0, -1)
+					case ActionScrollDown:
+						game.Scroll(0, 1)
+					case ActionZoom:
+						game.Zoom()
+					case ActionFollow:
+						game.ToggleFollow()
 					default:
-						game.KeyPressed(unicode.ToUpper(ev.Ch))
+						switch {
+						case game.end_reason != nil && unicode.ToUpper(ev.Ch) == 'R':
+							game = new_game()
+						case game.end_reason != nil && unicode.ToUpper(ev.Ch) == 'T':
+							filename := fmt.Sprintf("atc-transcript-%d.txt", game.seed)
+							game.transcript_error = game.WriteTranscript(filename)
+							if game.transcript_error == nil {
+								game.transcript_file = filename
+							}
+						default:
+							PromptKeys(ev, game)
+						}
+					}
+				}
+
+			case termbox.EventMouse:
+				v := game.Viewport()
+				switch {
+				case help_visible, delayed_visible, history_visible, transcript_visible:
+					// no mouse support in these dialogs
+				case planes_visible:
+					if ev.Key != termbox.MouseLeft {
+						break
+					}
+					if p := PlaneInWindow(game, ev.MouseX, ev.MouseY); p != nil {
+						game.Select(p)
+						planes_visible = false
+					}
+				case ev.Key == termbox.MouseWheelUp:
+					game.Scroll(0, -1)
+				case ev.Key == termbox.MouseWheelDown:
+					game.Scroll(0, 1)
+				case ev.Key != termbox.MouseLeft:
+					// only left clicks select
+				default:
+					if pos, ok := v.Cell(ev.MouseX, ev.MouseY); ok {
+						game.Click(pos)
+					} else if pos, ok := v.Minimap(game.board).Cell(ev.MouseX, ev.MouseY); ok && !v.Fits(game.board) {
+						game.CenterView(pos)
 					}
 				}
 
@@ -165,6 +310,33 @@ func RunGame(rules *GameRules, board *Board, diff *Difficulty, seed int64) {
 	}
 }
 
+// prompt editing and command input
+func PromptKeys(ev termbox.Event, game *GameState) {
+	switch ev.Ch {
+	case 0:
+		switch ev.Key {
+		case termbox.KeyBackspace, termbox.KeyBackspace2:
+			game.ci.Backspace()
+		case termbox.KeyDelete:
+			game.ci.Delete()
+		case termbox.KeyArrowLeft:
+			game.ci.MoveCursor(-1)
+		case termbox.KeyArrowRight:
+			game.ci.MoveCursor(1)
+		case termbox.KeyHome:
+			game.ci.CursorHome()
+		case termbox.KeyEnd:
+			game.ci.CursorEnd()
+		case termbox.KeyArrowUp:
+			game.ci.Recall(1)
+		case termbox.KeyArrowDown:
+			game.ci.Recall(-1)
+		}
+	default:
+		game.KeyPressed(unicode.ToUpper(ev.Ch))
+	}
+}
+
 func MainMenu() {
 	rules := &DEFAULT_RULES
 	board := DEFAULT_BOARD
@@ -174,54 +346,206 @@ func MainMenu() {
 	for {
 		menu := []string{
 			"Start Game",
+			"Campaign",
+			"Tutorial",
+			"Scenarios",
 			"",
 			Pad(30, "Board", "["+board.name+"]"),
 			Pad(30, "Rules", "["+rules.name+"]"),
 			Pad(30, "Difficulty", "["+diff.name+"]"),
 			"",
 			"Options",
+			"Editor",
 			"",
 			"Quit",
 		}
 
 		res := RunMenu("ATC - Air Traffic Control", menu, active)
 		switch res {
-		case MENU_ESCAPE, 8:
+		case MENU_ESCAPE, 12:
 			return
 		case 0:
 			seed := RandSeed()
-			RunGame(rules, board, diff, seed)
+			RunGame(func() *GameState { return NewGame(rules, board, diff, seed) })
+		case 1:
+			CampaignMenu()
 		case 2:
-			board = BoardMenu(board)
+			TutorialMenu()
 		case 3:
+			ScenarioMenu()
+		case 5:
+			board = BoardMenu(board)
+		case 6:
 			rules = RulesMenu(rules)
-		case 4:
+		case 7:
 			diff = DifficultyMenu(diff)
-		case 6:
+		case 9:
 			rules = OptionsMenu(rules)
+		case 10:
+			board = EditorMenu(board)
 		}
 		active = res
 	}
 }
 
 func BoardMenu(board *Board) *Board {
-	menu := make([]string, len(BOARDS))
+	boards := append(append([]*Board{}, BOARDS...), LoadBoardFiles()...)
+
+	menu := make([]string, len(boards), len(boards)+2)
 	active := 0
-	for nr, b := range BOARDS {
+	for nr, b := range boards {
 		menu[nr] = b.name
 		if b == board {
 			active = nr
 		}
 	}
+	menu = append(menu, "", "Generate new board")
 
 	for {
 		res := RunMenu("Choose Board", menu, active)
 		switch {
 		case res == MENU_ESCAPE:
 			return board
+		case res == len(menu)-1:
+			if b := GenerateMenu(); b != nil {
+				return b
+			}
 		case res >= 0:
-			return BOARDS[res]
+			return boards[res]
+		}
+	}
+}
+
+// sizes offered by the editor and generator menus
+var (
+	BOARD_WIDTHS  = []int{16, 20, 25, 31, 40, 60, 80}
+	BOARD_HEIGHTS = []int{12, 16, 20, 25, 30, 40, 60}
+)
+
+// choose a board to edit; returns the selected board
+func EditorMenu(board *Board) *Board {
+	WIDTH := 25
+	width, height := board.width, board.height
+	active := 0
+
+	for {
+		menu := []string{
+			"Edit copy of " + board.name,
+			"New board",
+			"",
+			Pad(WIDTH, "Width", "["+strconv.Itoa(width)+"]"),
+			Pad(WIDTH, "Height", "["+strconv.Itoa(height)+"]"),
+			"",
+			"Back",
+		}
+
+		res := RunMenu("Board Editor", menu, active)
+		var edited *Board
+		switch res {
+		case MENU_ESCAPE, 6:
+			return board
+		case 0:
+			b := board.Copy()
+			b.name = board.name + " (copy)"
+			edited = RunEditor(b, "")
+		case 1:
+			edited = RunEditor(NewBoard("New board", width, height), "")
+		case 3:
+			width = NextValue(BOARD_WIDTHS, width)
+		case 4:
+			height = NextValue(BOARD_HEIGHTS, height)
+		}
+		if edited != nil {
+			return edited
+		}
+		active = res
+	}
+}
+
+// atc edit <file>: edit a board file
+func EditCommand(filename string) {
+	fail := func(err error) {
+		termbox.Close()
+		fmt.Println(filename+":", err)
+		os.Exit(1)
+	}
+
+	// a new board for files that do not exist yet
+	board := NewBoard(strings.TrimSuffix(filepath.Base(filename), ".board"), 25, 20)
+	data, err := os.ReadFile(filename)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		fail(err)
+	default:
+		// invalid boards can be edited as long as they parse
+		b, err := ReadBoard(string(data))
+		if b == nil {
+			fail(err)
+		}
+		board = b
+	}
+	RunEditor(board, filename)
+}
+
+func GenerateMenu() *Board {
+	WIDTH := 25
+	spec := DEFAULT_BOARD_SPEC
+	spec.seed = RandSeed()
+	title := "Generate Board"
+	active := 0
+
+	num := func(n int) string {
+		return "[" + strconv.Itoa(n) + "]"
+	}
+
+	for {
+		menu := []string{
+			"Play",
+			"Save and play",
+			"",
+			Pad(WIDTH, "Width", num(spec.width)),
+			Pad(WIDTH, "Height", num(spec.height)),
+			Pad(WIDTH, "Entrypoints", num(spec.entrypoints)),
+			Pad(WIDTH, "Airports", num(spec.airports)),
+			Pad(WIDTH, "Navaids", num(spec.navaids)),
+			Pad(WIDTH, "NoFly regions", num(spec.nofly)),
+			Pad(WIDTH, "Seed", num(int(spec.seed))),
+			"",
+			"Back",
+		}
+
+		res := RunMenu(title, menu, active)
+		switch res {
+		case MENU_ESCAPE, 11:
+			return nil
+		case 0, 1:
+			b, err := GenerateBoard(spec)
+			if err == nil && res == 1 {
+				_, err = SaveBoard(b)
+			}
+			if err != nil {
+				title = err.Error()
+				break
+			}
+			return b
+		case 3:
+			spec.width = NextValue(BOARD_WIDTHS, spec.width)
+		case 4:
+			spec.height = NextValue(BOARD_HEIGHTS, spec.height)
+		case 5:
+			spec.entrypoints = NextValue([]int{2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 16, 20}, spec.entrypoints)
+		case 6:
+			spec.airports = NextValue([]int{0, 1, 2, 3, 4}, spec.airports)
+		case 7:
+			spec.navaids = NextValue([]int{0, 1, 2, 3, 4, 5, 6, 8, 10}, spec.navaids)
+		case 8:
+			spec.nofly = NextValue([]int{0, 1, 2, 3, 4, 5}, spec.nofly)
+		case 9:
+			spec.seed = RandSeed()
 		}
+		spec = spec.Clamp()
+		active = res
 	}
 }
 
@@ -250,7 +574,7 @@ func DifficultyMenu(diff *Difficulty) *Difficulty {
 	menu := make([]string, len(DIFFICULTIES))
 	active := 0
 	for nr, d := range DIFFICULTIES {
-		menu[nr] = d.name
+		menu[nr] = Pad(42, Pad(20, d.name, d.duration.String()), " ["+d.Profile().Preview(16)+"]")
 		if d == diff {
 			active = nr
 		}
@@ -280,6 +604,7 @@ func OptionsMenu(rules *GameRules) *GameRules {
 	}
 
 	r := *rules
+	title := "Choose options"
 
 	for {
 		menu := []string{
@@ -293,8 +618,12 @@ func OptionsMenu(rules *GameRules) *GameRules {
 			Pad(WIDTH, "Show pending planes", mark(r.show_pending_planes)),
 			Pad(WIDTH, ". delays commands", mark(r.delayed_commands)),
 			Pad(WIDTH, ", skips to next tick", mark(r.skip_to_next_tick)),
+			Pad(WIDTH, "Emergencies", mark(r.emergencies)),
+			"",
+			Pad(WIDTH, "Theme", "["+theme.name+"]"),
+			Pad(WIDTH, "Track lines", mark(show_tracks)),
 		}
-		res := RunMenu("Choose options", menu, active)
+		res := RunMenu(title, menu, active)
 		switch res {
 		case MENU_ESCAPE, 0:
 			if r == *rules {
@@ -317,20 +646,82 @@ func OptionsMenu(rules *GameRules) *GameRules {
 			r.delayed_commands = !r.delayed_commands
 		case 9:
 			r.skip_to_next_tick = !r.skip_to_next_tick
+		case 10:
+			r.emergencies = !r.emergencies
+		case 12, 13:
+			if res == 12 {
+				theme = NextTheme(theme)
+			} else {
+				show_tracks = !show_tracks
+			}
+			if err := WriteSettings(SettingsFile()); err != nil {
+				title = err.Error()
+			}
 		}
 		active = res
 	}
 }
 
+// atc generate: print a generated board in the board file format
+func GenerateCommand(args []string) {
+	spec := DEFAULT_BOARD_SPEC
+	spec.seed = RandSeed()
+
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	flags.IntVar(&spec.width, "width", spec.width, "board width")
+	flags.IntVar(&spec.height, "height", spec.height, "board height")
+	flags.IntVar(&spec.entrypoints, "entrypoints", spec.entrypoints, "number of entrypoints at the edges (2-20)")
+	flags.IntVar(&spec.airports, "airports", spec.airports, "number of airports (0-4)")
+	flags.IntVar(&spec.navaids, "navaids", spec.navaids, "number of navaids")
+	flags.IntVar(&spec.nofly, "nofly", spec.nofly, "number of nofly regions")
+	flags.Int64Var(&spec.seed, "seed", spec.seed, "random seed")
+	output := flags.String("o", "", "write the board to this file instead of stdout")
+	flags.Parse(args)
+
+	b, err := GenerateBoard(spec)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	board := FormatBoard(b)
+	if *output == "" {
+		fmt.Print(board)
+		return
+	}
+	if err := os.WriteFile(*output, []byte(board), 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
 func main() {
 	var err error
 
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		GenerateCommand(os.Args[2:])
+		return
+	}
+
 	err = termbox.Init()
 	if err != nil {
 		panic(err)
 	}
 	defer termbox.Close()
 	termbox.HideCursor()
+	termbox.SetInputMode(termbox.InputEsc | termbox.InputMouse)
+
+	key_bindings, err = ReadKeyBindings(KeyBindingsFile())
+	if err == nil {
+		err = ReadSettings(SettingsFile())
+	}
+	if err != nil {
+		termbox.Close()
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	voice = NewVoice(os.Getenv("ATC_VOICE"))
+	defer voice.Close()
 
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGTERM)
@@ -360,9 +751,28 @@ func main() {
 	usage := func() {
 		termbox.Close()
 		fmt.Println("usage: atc [time [planes]]")
+		fmt.Println("       atc generate [options]")
+		fmt.Println("       atc edit <board file>")
+		fmt.Println("       atc play <scenario file>")
 		os.Exit(1)
 	}
 
+	if len(os.Args) == 3 && os.Args[1] == "edit" {
+		EditCommand(os.Args[2])
+		return
+	}
+
+	if len(os.Args) == 3 && os.Args[1] == "play" {
+		sc, err := LoadScenario(os.Args[2])
+		if err != nil {
+			termbox.Close()
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		RunGame(sc.NewGame)
+		return
+	}
+
 	num_planes := 26
 	switch len(os.Args) {
 	case 3:
@@ -383,7 +793,7 @@ func main() {
 			num_planes: num_planes,
 		}
 		seed := RandSeed()
-		RunGame(&ATC_ORIGINAL_RULES, DEFAULT_BOARD, diff, seed)
+		RunGame(func() *GameState { return NewGame(&ATC_ORIGINAL_RULES, DEFAULT_BOARD, diff, seed) })
 	case 1:
 		MainMenu()
 	default:
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// speaks pilot messages
+type Voice interface {
+	Say(callsign rune, message string)
+	Close()
+}
+
+var voice Voice = NullVoice{}
+
+var PHONETIC = []string{
+	"Alfa", "Bravo", "Charlie", "Delta", "Echo", "Foxtrot", "Golf",
+	"Hotel", "India", "Juliett", "Kilo", "Lima", "Mike", "November",
+	"Oscar", "Papa", "Quebec", "Romeo", "Sierra", "Tango", "Uniform",
+	"Victor", "Whiskey", "X-ray", "Yankee", "Zulu",
+}
+
+// espeak voice variants; every callsign gets its own pilot voice
+var VOICE_VARIANTS = []string{"m1", "m2", "m3", "m4", "m5", "m6", "m7", "f1", "f2", "f3", "f4"}
+
+const VOICE_QUEUE = 8 // messages waiting to be spoken; more are dropped
+
+// voice for the ATC_VOICE environment variable:
+//
+//	(empty)     no audio
+//	espeak      speak with espeak (or any binary with espeak compatible arguments)
+//	wav:<dir>   write one WAV file per message to dir using espeak
+func NewVoice(spec string) Voice {
+	switch {
+	case spec == "" || spec == "none":
+		return NullVoice{}
+	case strings.HasPrefix(spec, "wav:"):
+		return NewSpeechVoice("espeak", strings.TrimPrefix(spec, "wav:"))
+	default:
+		return NewSpeechVoice(spec, "")
+	}
+}
+
+type NullVoice struct{}
+
+func (NullVoice) Say(callsign rune, message string) {}
+func (NullVoice) Close()                            {}
+
+// shells out to a TTS binary. Messages are spoken one after another.
+type SpeechVoice struct {
+	command string
+	dir     string // write WAV files instead of playing if set
+	count   int
+	queue   chan []string
+}
+
+func NewSpeechVoice(command string, dir string) *SpeechVoice {
+	v := &SpeechVoice{
+		command: command,
+		dir:     dir,
+		queue:   make(chan []string, VOICE_QUEUE),
+	}
+	go func() {
+		for args := range v.queue {
+			// errors are ignored; the game goes on without audio
+			_ = exec.Command(v.command, args...).Run()
+		}
+	}()
+	return v
+}
+
+func (v *SpeechVoice) Say(callsign rune, message string) {
+	text := message
+	variant := VOICE_VARIANTS[0]
+	if callsign >= 'A' && callsign <= 'Z' {
+		text = PHONETIC[callsign-'A'] + ", " + message
+		variant = VOICE_VARIANTS[int(callsign-'A')%len(VOICE_VARIANTS)]
+	}
+
+	args := []string{"-v", "en+" + variant}
+	if v.dir != "" {
+		v.count += 1
+		filename := fmt.Sprintf("%04d-%c.wav", v.count, callsign)
+		args = append(args, "-w", filepath.Join(v.dir, filename))
+	}
+	args = append(args, text)
+
+	select {
+	case v.queue <- args:
+	default:
+		// too much radio traffic; drop message
+	}
+}
+
+func (v *SpeechVoice) Close() {
+	close(v.queue)
+}
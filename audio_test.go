@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNewVoice(t *testing.T) {
+	for _, spec := range []string{"", "none"} {
+		if _, ok := NewVoice(spec).(NullVoice); !ok {
+			t.Errorf("%q should be silent", spec)
+		}
+	}
+
+	for spec, want := range map[string]SpeechVoice{
+		"espeak":       {command: "espeak"},
+		"/bin/say":     {command: "/bin/say"},
+		"wav:/tmp/atc": {command: "espeak", dir: "/tmp/atc"},
+	} {
+		v, ok := NewVoice(spec).(*SpeechVoice)
+		if !ok || v.command != want.command || v.dir != want.dir {
+			t.Errorf("%q: %v", spec, v)
+			continue
+		}
+		v.Close()
+	}
+
+	// games without a voice stay silent
+	g := testGame()
+	g.voice = nil
+	g.RecordPlane(g.planes[0], "roger")
+	if len(g.transcript) != 1 {
+		t.Error(g.transcript)
+	}
+}
@@ -2,18 +2,24 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 var (
 	DIFFICULTIES = []*Difficulty{
-		&Difficulty{"Beginner", 80 * Minutes, 26},
-		&Difficulty{"Easy", 60 * Minutes, 26},
-		&Difficulty{"Average", 40 * Minutes, 26},
-		&Difficulty{"Hard", 30 * Minutes, 26},
-		&Difficulty{"Expert", 20 * Minutes, 26},
-		&Difficulty{"Impossible", 16 * Minutes, 26},
+		&Difficulty{"Beginner", 80 * Minutes, 26, &PROFILE_FLAT},
+		&Difficulty{"Easy", 60 * Minutes, 26, &PROFILE_FLAT},
+		&Difficulty{"Average", 40 * Minutes, 26, &PROFILE_FLAT},
+		&Difficulty{"Hard", 30 * Minutes, 26, &PROFILE_FLAT},
+		&Difficulty{"Expert", 20 * Minutes, 26, &PROFILE_FLAT},
+		&Difficulty{"Impossible", 16 * Minutes, 26, &PROFILE_FLAT},
+		&Difficulty{"Ramp-up", 40 * Minutes, 26, &PROFILE_RAMP_UP},
+		&Difficulty{"Rush Hours", 40 * Minutes, 26, &PROFILE_RUSH_HOURS},
+		&Difficulty{"Arrival Banks", 40 * Minutes, 26, &PROFILE_ARRIVAL_BANKS},
 	}
 
 	DEFAULT_BOARD *Board = ParseBoard("ATC Standard", `
@@ -27,7 +33,7 @@ var (
         .........................
         .........................
         .........................
-        0....*...+=.........*...9
+        0....W...+=.........E...9
         .........................
         .........................
         .........................
@@ -63,7 +69,7 @@ var (
         ...............................
         ...............................
         ...............................
-        ....+=.........*...............
+        ....+=.........C...............
         ...............................
         ...............................
         ...............................
@@ -126,8 +132,8 @@ type Board struct {
 	width  int
 	height int
 
-	entrypoints map[rune]*EntryPoint
-	navaids     []Position
+	entrypoints map[string]*EntryPoint // by name
+	navaids     []Navaid
 	routes      []Route
 	nofly       []Position
 }
@@ -142,15 +148,33 @@ func (b Board) Contains(p Position) bool {
 	return true
 }
 
-func (b *Board) GetNavaid(p Position) *Position {
+func (b *Board) GetNavaid(p Position) *Navaid {
 	for _, navaid := range b.navaids {
-		if navaid == p {
+		if navaid.Position == p {
 			return &navaid
 		}
 	}
 	return nil
 }
 
+func (b *Board) FindNavaid(name rune) *Navaid {
+	for _, navaid := range b.navaids {
+		if navaid.name != 0 && navaid.name == name {
+			return &navaid
+		}
+	}
+	return nil
+}
+
+func (b *Board) IsNofly(p Position) bool {
+	for _, nf := range b.nofly {
+		if nf == p {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *Board) GetEntryPoint(p Position) *EntryPoint {
 	for _, ep := range b.entrypoints {
 		if ep.Position == p {
@@ -160,29 +184,144 @@ func (b *Board) GetEntryPoint(p Position) *EntryPoint {
 	return nil
 }
 
+func (b *Board) NearestAirport(p Position) *EntryPoint {
+	var nearest *EntryPoint
+	for _, ep := range b.entrypoints {
+		if !ep.is_airport {
+			continue
+		}
+		if nearest == nil || p.Distance(ep.Position) < p.Distance(nearest.Position) {
+			nearest = ep
+		}
+	}
+	return nearest
+}
+
+// find entrypoint, airport or named navaid by its name
+func (b *Board) FindFix(name string) *Fix {
+	if ep, ok := b.entrypoints[name]; ok {
+		return &Fix{name: ep.name, Position: ep.Position}
+	}
+	if len(name) == 1 {
+		if navaid := b.FindNavaid(rune(name[0])); navaid != nil {
+			return &Fix{name: name, Position: navaid.Position}
+		}
+	}
+	return nil
+}
+
+// entrypoint, airport or named navaid at pos; nil if there is none
+func (b *Board) FixAt(pos Position) *Fix {
+	for _, name := range b.FixNames(false) {
+		if fix := b.FindFix(name); fix.Position == pos {
+			return fix
+		}
+	}
+	return nil
+}
+
+// names of the entrypoints (or only the airports) and named navaids
+func (b *Board) FixNames(airports bool) []string {
+	names := make([]string, 0, len(b.entrypoints)+len(b.navaids))
+	for name, ep := range b.entrypoints {
+		if ep.is_airport || !airports {
+			names = append(names, name)
+		}
+	}
+	for _, navaid := range b.navaids {
+		if navaid.name != 0 && !airports {
+			names = append(names, string(navaid.name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// letters for the fixes declared by name; lowercase letters of the grid
+// (x: nofly) are left out
+const FIX_KEYS = "abcdefghijklmnopqrstuvwyz"
+
+// letters drawn on the radar for the fixes declared by name; the names
+// do not fit in a cell. Validate rejects more names than letters.
+func (b *Board) FixKeys() map[string]rune {
+	keys := make(map[string]rune)
+	for _, name := range b.FixNames(false) {
+		if ep, ok := b.entrypoints[name]; ok && ep.IsDeclared() && len(keys) < len(FIX_KEYS) {
+			keys[name] = rune(FIX_KEYS[len(keys)])
+		}
+	}
+	return keys
+}
+
+// a position on the board that planes can be sent to
+type Fix struct {
+	name string
+	Position
+}
+
+func (f Fix) String() string {
+	if f.name == "" {
+		return fmt.Sprintf("%d/%d", f.x, f.y)
+	}
+	return f.name
+}
+
+type Navaid struct {
+	name rune // 'A'-'Z'; 0 for anonymous navaids
+	Position
+}
+
+func (n Navaid) String() string {
+	if n.name == 0 {
+		return "*"
+	}
+	return "*" + string(n.name)
+}
+
 type EntryPoint struct {
-	sign rune
+	name string // grid sign or declared identifier
 	Position
 	Direction
 	is_airport bool
 }
 
+// declared by name instead of a sign in the grid
+func (ep EntryPoint) IsDeclared() bool {
+	return len(ep.name) > 1
+}
+
 type Route struct {
-	entry rune
-	exit  rune
+	entry string
+	exit  string
 	Direction
 	weight int
 }
 
 func (r Route) String() string {
-	return fmt.Sprintf("%s-%s", string(r.entry), string(r.exit))
+	return fmt.Sprintf("%s-%s", r.entry, r.exit)
+}
+
+const AIRPORT_SIGNS = "%="
+
+// names of declared entrypoints and airports: at least two letters or
+// digits, so they never clash with grid signs and navaids
+func IsFixName(name string) bool {
+	if len(name) < 2 {
+		return false
+	}
+	for _, ch := range name {
+		if !(ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9') {
+			return false
+		}
+	}
+	return true
 }
 
 func ParseBoard(name string, s string, rs string) *Board {
 	b := &Board{
 		name:        name,
-		entrypoints: make(map[rune]*EntryPoint),
-		navaids:     make([]Position, 0),
+		entrypoints: make(map[string]*EntryPoint),
+		navaids:     make([]Navaid, 0),
 		routes:      make([]Route, 0),
 		nofly:       make([]Position, 0),
 	}
@@ -197,8 +336,7 @@ func ParseBoard(name string, s string, rs string) *Board {
 			if b.width == 0 {
 				b.width = len(l)
 			} else if b.width != len(l) {
-				fmt.Println(l, len(l), b.width)
-				panic("inconsistent width")
+				panic(fmt.Sprintf("inconsistent width: %q", l))
 			}
 		}
 	}
@@ -215,22 +353,25 @@ func ParseBoard(name string, s string, rs string) *Board {
 			ch := lines[y][x]
 			switch ch {
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-				b.entrypoints[rune(ch)] = &EntryPoint{
-					sign:       rune(ch),
+				b.entrypoints[string(ch)] = &EntryPoint{
+					name:       string(ch),
 					Position:   pos,
 					is_airport: false,
 				}
 			case '%', '=':
 				// find direction for airport
-				var dir Direction
+				dir := Direction(DIR_MAX)
 				for _, d := range DIRECTIONS {
 					pos2 := pos.Move(d, 1)
-					if lines[pos2.y][pos2.x] == '+' {
+					if b.Contains(pos2) && lines[pos2.y][pos2.x] == '+' {
 						dir = d
 					}
 				}
-				b.entrypoints[rune(ch)] = &EntryPoint{
-					sign:       rune(ch),
+				if dir == DIR_MAX {
+					panic("no direction marker for airport " + string(ch))
+				}
+				b.entrypoints[string(ch)] = &EntryPoint{
+					name:       string(ch),
 					Position:   pos,
 					Direction:  dir,
 					is_airport: true,
@@ -238,7 +379,13 @@ func ParseBoard(name string, s string, rs string) *Board {
 			case '+':
 				// direction marker for Airport
 			case '*':
-				b.navaids = append(b.navaids, pos)
+				b.navaids = append(b.navaids, Navaid{Position: pos})
+			case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
+				'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+				if b.FindNavaid(rune(ch)) != nil {
+					panic("duplicate navaid: " + string(ch))
+				}
+				b.navaids = append(b.navaids, Navaid{name: rune(ch), Position: pos})
 			case 'x':
 				b.nofly = append(b.nofly, pos)
 			case '.':
@@ -248,39 +395,340 @@ func ParseBoard(name string, s string, rs string) *Board {
 		}
 	}
 
-	// TODO: better parsing
 	for _, l := range strings.Split(rs, "\n") {
-		l = strings.Trim(l, " \r\n")
-		if l == "" || l[0] == '#' {
+		b.routes = append(b.routes, b.parse_routes(l)...)
+	}
+
+	return b
+}
+
+// parse a route line "<weight>: <entry>-<exit>-<direction> ..."
+func (b *Board) parse_routes(l string) []Route {
+	res := make([]Route, 0)
+
+	// TODO: better parsing
+	l = strings.Trim(l, " \r\n")
+	if l == "" || l[0] == '#' {
+		return res
+	}
+
+	parts := strings.SplitN(l, ":", 2)
+	weight, _ := strconv.Atoi(parts[0])
+	routes := strings.Split(parts[1], " ")
+	for _, r := range routes {
+		if r == "" {
 			continue
 		}
 
-		parts := strings.SplitN(l, ":", 2)
-		weight, _ := strconv.Atoi(parts[0])
-		routes := strings.Split(parts[1], " ")
-		for _, r := range routes {
-			if r == "" {
-				continue
-			}
+		r_parts := strings.SplitN(r, "-", 3)
 
-			r_parts := strings.SplitN(r, "-", 3)
+		if len(r_parts) != 3 {
+			panic("expected <entry>-<exit>-<direction>: " + r)
+		}
+		route := Route{
+			entry:     r_parts[0],
+			exit:      r_parts[1],
+			Direction: ParseDirection(r_parts[2]),
+			weight:    weight,
+		}
+		_, ok_entry := b.entrypoints[route.entry]
+		_, ok_exit := b.entrypoints[route.exit]
+		if !ok_entry || !ok_exit {
+			panic("unknown entrypoint: " + route.entry + " or " + route.exit)
+		}
+		res = append(res, route)
+	}
+	return res
+}
 
-			route := Route{
-				entry:     rune(r_parts[0][0]),
-				exit:      rune(r_parts[1][0]),
-				Direction: ParseDirection(r_parts[2]),
-				weight:    weight,
-			}
-			_, ok_entry := b.entrypoints[route.entry]
-			_, ok_exit := b.entrypoints[route.exit]
-			if !ok_entry || !ok_exit {
-				panic("unknown entrypoint: " + string(route.entry) + " or " + string(route.exit))
-			}
-			b.routes = append(b.routes, route)
+// parse a route line of the board file
+func (b *Board) ReadRoutes(l string) (routes []Route, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			routes, err = nil, fmt.Errorf("invalid route: %v", r)
+		}
+	}()
+	return b.parse_routes(l), nil
+}
+
+// parse a declaration "entry <name> <x> <y>" or
+// "airport <name> <x> <y> <runway direction>"
+func (b *Board) parse_declaration(l string) {
+	fields := strings.Fields(l)
+	is_airport := fields[0] == "airport"
+	if len(fields) != 4 && !is_airport || len(fields) != 5 && is_airport {
+		panic("invalid declaration: " + l)
+	}
+
+	name := fields[1]
+	if !IsFixName(name) {
+		panic("invalid name: " + name)
+	}
+	if _, ok := b.entrypoints[name]; ok {
+		panic("duplicate entrypoint: " + name)
+	}
+	x, err_x := strconv.Atoi(fields[2])
+	y, err_y := strconv.Atoi(fields[3])
+	pos := Position{x, y}
+	if err_x != nil || err_y != nil || !b.Contains(pos) {
+		panic("invalid position: " + l)
+	}
+	if b.GetEntryPoint(pos) != nil || b.GetNavaid(pos) != nil {
+		panic("position already used: " + l)
+	}
+
+	ep := &EntryPoint{name: name, Position: pos, is_airport: is_airport}
+	if is_airport {
+		ep.Direction = ParseDirection(fields[4])
+	}
+	b.entrypoints[name] = ep
+}
+
+// parse a board file:
+//
+//	name: <name>
+//	<grid lines>
+//	entry <name> <x> <y>
+//	airport <name> <x> <y> <runway direction>
+//	<weight>: <entry>-<exit>-<direction> ...
+//
+// The grid holds entrypoints 0-9 and airports % and =; more are declared
+// by name. Lines starting with # are comments.
+func ReadBoard(s string) (b *Board, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, err = nil, fmt.Errorf("invalid board: %v", r)
+		}
+	}()
 
+	name := "Unnamed"
+	var grid, declarations, routes []string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "" || strings.HasPrefix(l, "#"):
+		case strings.HasPrefix(l, "name:"):
+			name = strings.TrimSpace(strings.TrimPrefix(l, "name:"))
+		case strings.HasPrefix(l, "entry ") || strings.HasPrefix(l, "airport "):
+			declarations = append(declarations, l)
+		case strings.Contains(l, ":"):
+			routes = append(routes, l)
+		default:
+			grid = append(grid, l)
 		}
+	}
 
+	b = ParseBoard(name, strings.Join(grid, "\n"), "")
+	for _, l := range declarations {
+		b.parse_declaration(l)
+	}
+	for _, l := range routes {
+		b.routes = append(b.routes, b.parse_routes(l)...)
 	}
+	return b, b.Validate()
+}
 
-	return b
+func LoadBoard(filename string) (*Board, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ReadBoard(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return b, nil
+}
+
+func BoardsDir() string {
+	return ConfigFile("boards")
+}
+
+// boards saved in the boards directory; unreadable files are skipped
+func LoadBoardFiles() []*Board {
+	boards := make([]*Board, 0)
+	dir := BoardsDir()
+	if dir == "" {
+		return boards
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.board"))
+	sort.Strings(files)
+	for _, filename := range files {
+		if b, err := LoadBoard(filename); err == nil {
+			boards = append(boards, b)
+		}
+	}
+	return boards
+}
+
+// save a board to the boards directory. Returns the filename.
+func SaveBoard(b *Board) (string, error) {
+	dir := BoardsDir()
+	if dir == "" {
+		return "", fmt.Errorf("no config directory")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, b.name)
+	filename := filepath.Join(dir, name+".board")
+	return filename, os.WriteFile(filename, []byte(FormatBoard(b)), 0644)
+}
+
+// board cells as in the board file
+func (b *Board) Grid() [][]byte {
+	grid := make([][]byte, b.height)
+	for y := range grid {
+		grid[y] = []byte(strings.Repeat(".", b.width))
+	}
+	set := func(pos Position, ch byte) {
+		if b.Contains(pos) {
+			grid[pos.y][pos.x] = ch
+		}
+	}
+
+	for _, nf := range b.nofly {
+		set(nf, 'x')
+	}
+	for _, navaid := range b.navaids {
+		if navaid.name == 0 {
+			set(navaid.Position, '*')
+		} else {
+			set(navaid.Position, byte(navaid.name))
+		}
+	}
+	for _, ep := range b.entrypoints {
+		if !ep.IsDeclared() {
+			set(ep.Position, ep.name[0])
+		}
+		if ep.is_airport {
+			set(ep.Position.Move(ep.Direction, 1), '+')
+		}
+	}
+	return grid
+}
+
+func (b *Board) Copy() *Board {
+	b2 := *b
+	b2.entrypoints = make(map[string]*EntryPoint)
+	for name, ep := range b.entrypoints {
+		ep2 := *ep
+		b2.entrypoints[name] = &ep2
+	}
+	b2.navaids = append([]Navaid{}, b.navaids...)
+	b2.routes = append([]Route{}, b.routes...)
+	b2.nofly = append([]Position{}, b.nofly...)
+	return &b2
+}
+
+// board in the file format read by ReadBoard
+func FormatBoard(b *Board) string {
+	var res strings.Builder
+	fmt.Fprintf(&res, "name: %s\n\n", b.name)
+	for _, line := range b.Grid() {
+		res.WriteString(string(line) + "\n")
+	}
+
+	declared := make([]string, 0)
+	for name, ep := range b.entrypoints {
+		if ep.IsDeclared() {
+			declared = append(declared, name)
+		}
+	}
+	if len(declared) > 0 {
+		res.WriteString("\n")
+	}
+	sort.Strings(declared)
+	for _, name := range declared {
+		ep := b.entrypoints[name]
+		if ep.is_airport {
+			fmt.Fprintf(&res, "airport %s %d %d %s\n", name, ep.x, ep.y, ep.Direction)
+		} else {
+			fmt.Fprintf(&res, "entry %s %d %d\n", name, ep.x, ep.y)
+		}
+	}
+	res.WriteString("\n# Format: weight: entry-exit-direction\n")
+
+	// one line per weight in order of appearance
+	weights := make([]int, 0)
+	by_weight := make(map[int][]string)
+	for _, r := range b.routes {
+		if _, ok := by_weight[r.weight]; !ok {
+			weights = append(weights, r.weight)
+		}
+		by_weight[r.weight] = append(by_weight[r.weight], r.String()+"-"+r.Direction.String())
+	}
+	for _, w := range weights {
+		fmt.Fprintf(&res, "%d: %s\n", w, strings.Join(by_weight[w], " "))
+	}
+	return res.String()
+}
+
+// check that the board is playable
+func (b *Board) Validate() error {
+	if b.width < 5 || b.height < 5 {
+		return fmt.Errorf("board is too small")
+	}
+	if len(b.routes) == 0 {
+		return fmt.Errorf("no routes")
+	}
+
+	declared := 0
+	for _, ep := range b.entrypoints {
+		if ep.IsDeclared() {
+			declared += 1
+		}
+	}
+	if declared > len(FIX_KEYS) {
+		return fmt.Errorf("%d declared names, at most %d", declared, len(FIX_KEYS))
+	}
+
+	for _, ep := range b.entrypoints {
+		edge := ep.x == 0 || ep.y == 0 || ep.x == b.width-1 || ep.y == b.height-1
+		if !ep.is_airport && !edge {
+			return fmt.Errorf("entrypoint %s is not at the edge", ep.name)
+		}
+		if !ep.is_airport {
+			continue
+		}
+
+		marker := ep.Move(ep.Direction, 1)
+		if !b.Contains(marker) {
+			return fmt.Errorf("airport %s: runway leaves the board", ep.name)
+		}
+		if b.GetEntryPoint(marker) != nil || b.GetNavaid(marker) != nil || b.IsNofly(marker) {
+			return fmt.Errorf("airport %s: runway marker is blocked", ep.name)
+		}
+	}
+
+	weight := 0
+	for _, r := range b.routes {
+		entry, ok_entry := b.entrypoints[r.entry]
+		_, ok_exit := b.entrypoints[r.exit]
+		if !ok_entry || !ok_exit {
+			return fmt.Errorf("route %s: unknown entrypoint", r)
+		}
+		if entry.is_airport && r.Direction != entry.Direction {
+			return fmt.Errorf("route %s: departures must use the airport direction %s", r, entry.Direction)
+		}
+		if !entry.is_airport && !b.Contains(entry.Move(r.Direction, 1)) {
+			return fmt.Errorf("route %s: direction %s leaves the board", r, r.Direction)
+		}
+		if r.weight < 0 {
+			return fmt.Errorf("route %s: negative weight", r)
+		}
+		weight += r.weight
+	}
+	if weight == 0 {
+		return fmt.Errorf("all routes have weight 0")
+	}
+	return nil
 }
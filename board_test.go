@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBoardFile(t *testing.T) {
+	for _, b := range BOARDS {
+		if err := b.Validate(); err != nil {
+			t.Error(b.name, err)
+		}
+
+		s := FormatBoard(b)
+		b2, err := ReadBoard(s)
+		if err != nil {
+			t.Fatal(b.name, err, s)
+		}
+		if b2.name != b.name || FormatBoard(b2) != s {
+			t.Error(b.name, "differs after reading:\n", FormatBoard(b2))
+		}
+	}
+
+	for _, s := range []string{"", "..1\n.2.", "0....\n.....\n.....\n.....\n....9\n1: 0-9-W", "0....\n..%..\n.....\n.....\n....9"} {
+		if _, err := ReadBoard(s); err == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+}
+
+const NAMED_BOARD = `
+name: Named
+0.........
+..........
+.....%....
+.....+....
+..........
+..........
+.........1
+
+entry LA 0 5
+entry LAX 9 3
+airport KJFK 3 6 E
+airport KLGA 7 1 SW
+
+1: 0-1-SE LA-LAX-E LAX-KJFK-W KJFK-LA-E KLGA-%-SW %-LAX-S
+`
+
+func TestDeclaredFixes(t *testing.T) {
+	b, err := ReadBoard(NAMED_BOARD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep := b.entrypoints["KJFK"]; ep == nil || !ep.is_airport || ep.Position != (Position{3, 6}) {
+		t.Error(ep)
+	}
+	if len(b.FixNames(true)) != 3 || len(b.FixNames(false)) != 7 {
+		t.Error(b.FixNames(false))
+	}
+
+	// names on the radar by key
+	if keys := b.FixKeys(); len(keys) != 4 || keys["KJFK"] != 'a' || keys["LAX"] != 'd' {
+		t.Error(keys)
+	}
+	if legend := FixLegend(b, 14); len(legend) != 2 || legend[0] != "a KJFK  b KLGA" || legend[1] != "c LA  d LAX" {
+		t.Errorf("%q", legend)
+	}
+
+	// one letter per name, but none used on the grid
+	many := b.Copy()
+	corner := Position{many.width - 1, many.height - 1}
+	for n := 0; len(many.FixKeys()) < len(FIX_KEYS); n++ {
+		name := fmt.Sprintf("EP%d", n)
+		many.entrypoints[name] = &EntryPoint{name: name, Position: corner}
+	}
+	if err := many.Validate(); err != nil {
+		t.Error(err)
+	}
+	for name, key := range many.FixKeys() {
+		if key == 'x' || key < 'a' || key > 'z' {
+			t.Errorf("%s drawn as %c", name, key)
+		}
+	}
+	many.entrypoints["EP99"] = &EntryPoint{name: "EP99", Position: corner}
+	if err := many.Validate(); err == nil || len(many.FixKeys()) != len(FIX_KEYS) {
+		t.Error("too many names accepted")
+	}
+
+	s := FormatBoard(b)
+	if b2, err := ReadBoard(s); err != nil || FormatBoard(b2) != s {
+		t.Error("differs after reading:", err, s)
+	}
+
+	for _, decl := range []string{"entry L 0 4", "entry la 0 4", "entry LA 0 4", "entry NEW 3 3", "entry NEW 0 0", "airport AP 4 4", "entry NEW 10 0"} {
+		if _, err := ReadBoard(NAMED_BOARD + decl); err == nil {
+			t.Errorf("%q should be invalid", decl)
+		}
+	}
+}
+
+func TestGenerateBoard(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		spec := DEFAULT_BOARD_SPEC
+		spec.seed = seed
+		spec.width = 16 + int(seed)%50
+		spec.airports = int(seed) % 5
+		spec.entrypoints = 2 + int(seed)%19
+		spec.nofly = int(seed) % 4
+
+		// crowded specs may not fit, but always fail the same way
+		b, err := GenerateBoard(spec)
+		again, err2 := GenerateBoard(spec)
+		if err != nil {
+			if err2 == nil || err.Error() != err2.Error() {
+				t.Error(seed, "not reproducible:", err, err2)
+			}
+			continue
+		}
+		s := FormatBoard(b)
+		if s != FormatBoard(again) {
+			t.Error(seed, "not reproducible")
+		}
+		if _, err := ReadBoard(s); err != nil {
+			t.Error(seed, err, s)
+		}
+	}
+
+	spec := DEFAULT_BOARD_SPEC
+	spec.width, spec.height = 16, 10
+	spec.entrypoints = 60
+	if _, err := GenerateBoard(spec); err == nil {
+		t.Error("too many entrypoints should fail")
+	}
+	spec = DEFAULT_BOARD_SPEC
+	spec.width, spec.height = 16, 10
+	spec.airports = 9
+	if _, err := GenerateBoard(spec); err == nil {
+		t.Error("too many airports should fail")
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	termbox "github.com/nsf/termbox-go"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a scenario of the campaign
+type Mission struct {
+	name     string
+	briefing []string
+	board    *Board
+	rules    *GameRules
+	diff     *Difficulty
+	seed     int64 // same traffic on every try
+	events   []MissionEvent
+	requires []string // missions to complete first
+}
+
+// scripted event during a mission
+type MissionEvent struct {
+	after     Ticks // game time since the start
+	message   string
+	emergency bool // declare an emergency for a flying plane
+}
+
+const EVENT_TIME = 1 * Minutes // event messages stay in the status area
+
+var (
+	// emergencies only by events
+	CAMPAIGN_RULES = GameRules{
+		name: "Campaign",
+
+		last_plane_start: 15 * Minutes,
+
+		skip_to_next_tick: true,
+		delayed_commands:  true,
+
+		have_jet:       true,
+		have_prop:      true,
+		have_heli:      true,
+		have_blackbird: true,
+
+		show_pending_planes: false,
+		emergencies:         false,
+	}
+
+	CAMPAIGN = []*Mission{
+		&Mission{
+			name: "First Shift",
+			briefing: []string{
+				"Welcome to the tower. Traffic is light today:",
+				"jets and props only, as in the original game.",
+				"Bring every plane to its exit at level 5",
+				"or land it at its airport.",
+			},
+			board: DEFAULT_BOARD,
+			rules: &ATC_ORIGINAL_RULES,
+			diff:  DIFFICULTIES[0],
+			seed:  1001,
+		},
+		&Mission{
+			name: "Crossways",
+			briefing: []string{
+				"Helicopters join the traffic. They turn on the",
+				"spot and can hover with the keep command.",
+				"The single airport is busy: plan the approaches.",
+			},
+			board:    CROSSWAYS_BOARD,
+			rules:    &CAMPAIGN_RULES,
+			diff:     DIFFICULTIES[1],
+			seed:     1002,
+			requires: []string{"First Shift"},
+		},
+		&Mission{
+			name: "Mayday",
+			briefing: []string{
+				"Expect emergencies. Planes in trouble must land",
+				"at the nearest airport in time.",
+			},
+			board: DEFAULT_BOARD,
+			rules: &CAMPAIGN_RULES,
+			diff:  DIFFICULTIES[1],
+			seed:  1003,
+			events: []MissionEvent{
+				{after: 6 * Minutes, message: "Weather deteriorating, expect problems", emergency: true},
+				{after: 20 * Minutes, message: "Another aircraft reports trouble", emergency: true},
+			},
+			requires: []string{"First Shift"},
+		},
+		&Mission{
+			name: "Closed Airspace",
+			briefing: []string{
+				"Military exercise: the center of the sector is",
+				"closed. Route the traffic around it.",
+			},
+			board:    NOFLY_BOARD,
+			rules:    &CAMPAIGN_RULES,
+			diff:     DIFFICULTIES[2],
+			seed:     1004,
+			requires: []string{"Crossways"},
+		},
+		&Mission{
+			name: "Rush Hour",
+			briefing: []string{
+				"Heavy traffic on the crossways and a sick",
+				"passenger on one of the flights.",
+			},
+			board: CROSSWAYS_BOARD,
+			rules: &CAMPAIGN_RULES,
+			diff:  DIFFICULTIES[3],
+			seed:  1005,
+			events: []MissionEvent{
+				{after: 10 * Minutes, message: "Medical emergency reported", emergency: true},
+			},
+			requires: []string{"Mayday", "Closed Airspace"},
+		},
+		&Mission{
+			name: "Final Exam",
+			briefing: []string{
+				"Everything at once. Good luck.",
+			},
+			board: DEFAULT_BOARD,
+			rules: &DEFAULT_RULES,
+			diff:  DIFFICULTIES[4],
+			seed:  1006,
+			events: []MissionEvent{
+				{after: 8 * Minutes, message: "Storm cell approaching", emergency: true},
+			},
+			requires: []string{"Rush Hour"},
+		},
+	}
+)
+
+// best time left of the completed missions
+type Progress map[string]Ticks
+
+func (p Progress) Unlocked(m *Mission) bool {
+	for _, name := range m.requires {
+		if _, ok := p[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func ProgressFile() string {
+	return ConfigFile("campaign.conf")
+}
+
+// read progress: lines "<mission> = <ticks left>". Nothing is read from
+// an invalid file.
+func ReadProgress(filename string) (Progress, error) {
+	progress := make(Progress)
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return progress, nil
+	} else if err != nil {
+		return make(Progress), err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line_nr := 1; scanner.Scan(); line_nr += 1 {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return make(Progress), fmt.Errorf("%s:%d: expected <mission> = <ticks>", filename, line_nr)
+		}
+		ticks, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return make(Progress), fmt.Errorf("%s:%d: %s", filename, line_nr, err)
+		}
+		progress[strings.TrimSpace(parts[0])] = Ticks(ticks)
+	}
+	if err := scanner.Err(); err != nil {
+		return make(Progress), err
+	}
+	return progress, nil
+}
+
+func WriteProgress(filename string, progress Progress) error {
+	if filename == "" {
+		return fmt.Errorf("no config directory")
+	}
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(progress))
+	for name := range progress {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&res, "%s = %d\n", name, progress[name])
+	}
+	return os.WriteFile(filename, []byte(res.String()), 0644)
+}
+
+func (m *Mission) NewGame() *GameState {
+	game := NewGame(m.rules, m.board, m.diff, m.seed)
+	game.events = append([]MissionEvent{}, m.events...)
+	return game
+}
+
+// fire the mission events that are due
+func (g *GameState) runEvents() {
+	pending := g.events[:0]
+	for _, event := range g.events {
+		if g.duration-g.clock < event.after {
+			pending = append(pending, event)
+			continue
+		}
+		if event.emergency && !g.forceEmergency() {
+			// no plane in the air yet
+			pending = append(pending, event)
+			continue
+		}
+		g.Record("", event.message)
+		g.event = g.transcript[len(g.transcript)-1]
+	}
+	g.events = pending
+}
+
+// message of the last mission event while it is recent; "" otherwise
+func (g *GameState) EventMessage() string {
+	if g.event.message == "" || g.event.clock-g.clock >= EVENT_TIME {
+		return ""
+	}
+	return g.event.message
+}
+
+// planes landed or departed
+func (g *GameState) Handled() int {
+	handled := 0
+	for _, p := range g.planes {
+		if p.IsDone() {
+			handled += 1
+		}
+	}
+	return handled
+}
+
+func CampaignMenu() {
+	// an unreadable file is not overwritten
+	filename := ProgressFile()
+	progress, err := ReadProgress(filename)
+	title := "Campaign"
+	if err != nil {
+		title = err.Error()
+		filename = ""
+	}
+
+	active := 0
+	for {
+		menu := make([]string, 0, len(CAMPAIGN)+2)
+		for nr, m := range CAMPAIGN {
+			status := ""
+			if best, ok := progress[m.name]; ok {
+				status = "[done " + best.String() + "]"
+			} else if !progress.Unlocked(m) {
+				status = "[locked]"
+			}
+			menu = append(menu, Pad(36, fmt.Sprintf("%d. %s", nr+1, m.name), status))
+		}
+		menu = append(menu, "", "Back")
+
+		res := RunMenu(title, menu, active)
+		switch {
+		case res == MENU_ESCAPE || res == len(menu)-1:
+			return
+		case res < len(CAMPAIGN) && !progress.Unlocked(CAMPAIGN[res]):
+			title = "Complete " + strings.Join(CAMPAIGN[res].requires, " and ") + " first"
+		case res < len(CAMPAIGN):
+			title = "Campaign"
+			if err := RunMission(CAMPAIGN[res], progress, filename); err != nil {
+				title = err.Error()
+			}
+		}
+		active = res
+	}
+}
+
+// briefing, game and results of a mission. Saves the progress to
+// filename unless it is "".
+func RunMission(m *Mission, progress Progress, filename string) error {
+	briefing := append([]string{
+		fmt.Sprintf("%s / %s / %s", m.board.name, m.rules.name, m.diff.name),
+		"",
+	}, m.briefing...)
+	if !RunMessage("Mission: "+m.name, "Enter: start  Esc: back", briefing) {
+		return nil
+	}
+
+	game := RunGame(m.NewGame)
+	success := game.end_reason != nil && game.end_reason.success
+
+	result := "Aborted"
+	if game.end_reason != nil {
+		result = game.end_reason.message
+	}
+	lines := []string{
+		Pad(30, "Result", result),
+		Pad(30, "Planes handled", fmt.Sprintf("%d / %d", game.Handled(), len(game.planes))),
+		Pad(30, "Time left", game.clock.String()),
+	}
+
+	unlocked := make(map[*Mission]bool)
+	for _, other := range CAMPAIGN {
+		unlocked[other] = progress.Unlocked(other)
+	}
+
+	var err error
+	if success {
+		best, done := progress[m.name]
+		if !done || game.clock > best {
+			progress[m.name] = game.clock
+			if filename != "" {
+				err = WriteProgress(filename, progress)
+			} else {
+				err = fmt.Errorf("progress not saved: fix %s first", ProgressFile())
+			}
+		}
+		lines = append(lines, Pad(30, "Best", progress[m.name].String()))
+
+		for _, other := range CAMPAIGN {
+			if !unlocked[other] && progress.Unlocked(other) {
+				lines = append(lines, "Unlocked: "+other.name)
+			}
+		}
+	}
+
+	title := "Mission failed"
+	if success {
+		title = "Mission complete"
+	}
+	RunMessage(title, "Press Enter", lines)
+	return err
+}
+
+// show a window until a key is pressed. Returns false for Esc.
+func RunMessage(title string, footer string, lines []string) bool {
+	for {
+		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+		DrawWindow(title, footer, lines, nil)
+		termbox.Flush()
+
+		ev := <-events
+		if ev.Type == termbox.EventKey {
+			return ev.Key != termbox.KeyEsc
+		}
+	}
+}
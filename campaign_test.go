@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestCampaign(t *testing.T) {
+	done := make(Progress)
+	for _, m := range CAMPAIGN {
+		// missions only require earlier missions
+		if !done.Unlocked(m) {
+			t.Error(m.name, "requires a later or unknown mission", m.requires)
+		}
+		done[m.name] = Minutes
+	}
+
+	filename := t.TempDir() + "/campaign.conf"
+	if err := WriteProgress(filename, done); err != nil {
+		t.Fatal(err)
+	}
+	progress, err := ReadProgress(filename)
+	if err != nil || len(progress) != len(CAMPAIGN) || progress["Mayday"] != Minutes {
+		t.Error(progress, err)
+	}
+
+	// nothing of a damaged file, so it is not saved with missions missing
+	if err := os.WriteFile(filename, []byte("Mayday = 4\nbroken\nOther = 8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if progress, err := ReadProgress(filename); err == nil || len(progress) != 0 {
+		t.Error("damaged file:", progress, err)
+	}
+}
+
+func TestMissionEvents(t *testing.T) {
+	g := testGame()
+	g.rand = rand.New(rand.NewSource(1))
+	g.duration = g.clock
+	g.events = []MissionEvent{{after: 2, message: "trouble", emergency: true}}
+
+	g.runEvents()
+	if len(g.events) != 1 {
+		t.Error("event fired early")
+	}
+
+	g.clock -= 2
+	g.runEvents()
+	if len(g.events) != 0 || g.EventMessage() != "trouble" || !g.planes[0].HasEmergency() {
+		t.Error("event not fired", g.events, g.EventMessage())
+	}
+	if g.ci.reply != "" {
+		t.Error("event replaced the last reply:", g.ci.reply)
+	}
+
+	g.clock -= EVENT_TIME
+	if g.EventMessage() != "" {
+		t.Error("event still shown")
+	}
+}
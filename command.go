@@ -1,14 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
-	COMMANDS_WITHOUT_ARG = "SMPHK%="
-	COMMANDS_WITH_ARG    = "LRA"
+	COMMANDS_WITHOUT_ARG = "SMPHKC"
+	COMMANDS_WITH_ARG    = "LRAV"
+	COMMANDS_WITH_FIX    = "DT"
+	COMMANDS_AT_NAVAID   = "HWT"
+
+	CHAIN_PREFIX = "+"
+	REPEAT_KEY   = '"'
 )
 
 type Command struct {
@@ -18,142 +25,670 @@ type Command struct {
 	callsign rune
 	command  rune
 	arg      int
+	fix      string // entrypoint, airport or navaid for D; airport for T
+	at       rune   // named navaid for H, W and T
+	right    bool   // hold direction for W
+	target   *Fix   // board cell for D; set by mouse clicks
+
+	cond *Condition // apply once the condition is met
+}
+
+func (c Command) String() string {
+	return strings.Repeat(".", c.delayed) + string(c.callsign) + c.Instruction()
+}
+
+// command without callsign and delay
+func (c Command) Instruction() string {
+	res := ""
+	if c.cond != nil {
+		res += c.cond.String()
+	}
+	if c.at != 0 {
+		res += "@" + string(c.at)
+	}
+	if c.command == 'T' && len(c.fix) == 1 && strings.Contains(AIRPORT_SIGNS, c.fix) {
+		// short form for the airports of the grid
+		return res + c.fix
+	}
+	res += string(key_bindings.CommandKey(c.command))
+
+	switch {
+	case c.command == 'W' && c.right:
+		res += fmt.Sprintf("R%d", c.arg)
+	case c.command == 'W':
+		res += fmt.Sprintf("L%d", c.arg)
+	case strings.ContainsRune(COMMANDS_WITH_ARG, c.command):
+		res += strconv.Itoa(c.arg)
+	case c.target != nil:
+		res += c.target.String()
+	case strings.ContainsRune(COMMANDS_WITH_FIX, c.command):
+		res += c.fix
+	}
+	return res
+}
+
+// commands that can be typed again; board cells picked with the mouse
+// have no syntax and stay out of the history
+func (c Command) Recallable() bool {
+	return c.target == nil
+}
+
+type Condition struct {
+	fix   string // fix name or "*" for any navaid; "" for level conditions
+	level int
+}
+
+func (c Condition) String() string {
+	if c.fix != "" {
+		return "@" + c.fix
+	}
+	return fmt.Sprintf("^%d", c.level)
+}
+
+func (c Condition) Met(b *Board, p *Plane) bool {
+	switch {
+	case c.fix == "*":
+		return b.GetNavaid(p.Position) != nil
+	case c.fix != "":
+		fix := b.FindFix(c.fix)
+		return fix != nil && fix.Position == p.Position
+	default:
+		return p.height == c.level
+	}
 }
 
-func (c *Command) Apply(p *Plane) string {
+// apply command to plane. Returns the reply and whether the command was accepted.
+func (c *Command) Apply(g *GameState, p *Plane) (string, bool) {
+	return c.execute(g, p, true)
+}
+
+// levels: check the levels in holding stacks
+func (c *Command) execute(g *GameState, p *Plane, levels bool) (string, bool) {
 	if !c.valid {
-		return "--- Say Again? ---"
+		return "--- Say Again? ---", false
 	}
 
 	if p == nil {
-		return "---------"
+		return "---------", false
 	}
 
 	if c.command == 'S' && p.IsActive() {
-		return p.StateMessage()
+		return p.StateMessage(), true
 	}
 
 	if !p.AcceptsCommands() {
-		return "---------"
+		return "---------", false
 	}
 
-	var res bool
+	if c.cond != nil {
+		if c.cond.fix != "" && c.cond.fix != "*" && g.board.FindFix(c.cond.fix) == nil {
+			return fmt.Sprintf("Unable, unknown fix %s", c.cond.fix), false
+		}
 
+		// the command must be possible now, tried on a copy of the plane
+		inner := *c
+		inner.cond = nil
+		dry := *p
+		dry.conditions = nil
+		err := inner.check(g, &dry)
+		if err == nil {
+			err = inner.apply(g, &dry)
+		}
+		if err != nil {
+			return "Unable, " + err.Error(), false
+		}
+		p.conditions = append(p.conditions, c)
+		return c.Readback(p), true
+	}
+
+	err := c.check(g, p)
+	if err == nil && levels {
+		err = c.check_level(g, p)
+	}
+	if err == nil {
+		err = c.apply(g, p)
+	}
+	if err != nil {
+		return "Unable, " + err.Error(), false
+	}
+	return c.Readback(p), true
+}
+
+// checks that need the game state
+func (c *Command) check(g *GameState, p *Plane) error {
+	if c.at != 0 {
+		if g.board.FindNavaid(c.at) == nil {
+			return fmt.Errorf("unknown navaid %c", c.at)
+		}
+	}
+
+	if c.command == 'T' {
+		if ep, ok := g.board.entrypoints[c.fix]; !ok || !ep.is_airport {
+			return fmt.Errorf("unknown airport %s", c.fix)
+		}
+	}
+	return nil
+}
+
+// the level of the plane must be free in the holding stack
+func (c *Command) check_level(g *GameState, p *Plane) error {
+	if navaid := g.board.FindNavaid(c.at); navaid != nil && (c.command == 'H' || c.command == 'W') {
+		if other := g.HoldConflict(p, navaid.Position, p.want_height); other != nil {
+			return fmt.Errorf("level %d at %c occupied by %c",
+				p.want_height, c.at, other.callsign)
+		}
+	}
+
+	if p.is_holding && (c.command == 'A' || c.command == 'M') {
+		height := p.height
+		if c.command == 'A' {
+			height = c.arg
+		}
+		if other := g.HoldConflict(p, p.hold_pos, height); other != nil {
+			return fmt.Errorf("level %d in hold occupied by %c", height, other.callsign)
+		}
+	}
+	return nil
+}
+
+func (c *Command) apply(g *GameState, p *Plane) error {
 	switch c.command {
 	case 'L': // turn left 0-4
-		res = p.DoTurn(-c.arg)
+		return p.DoTurn(-c.arg)
 	case 'R': // turn right 0-4
-		res = p.DoTurn(c.arg)
+		return p.DoTurn(c.arg)
 	case 'A': // change altitude 0-5 (0: aproach)
-		res = p.DoHeight(c.arg)
-	// case 'S': handled above
+		return p.DoHeight(c.arg)
+	// case 'S': handled in Apply
+	case 'V': // speed 1-3 (slow, normal, fast)
+		switch c.arg {
+		case 1:
+			return p.DoSpeed(SpeedSlow)
+		case 2:
+			return p.DoSpeed(SpeedNormal)
+		case 3:
+			return p.DoSpeed(SpeedFast)
+		default:
+			return errors.New("speed is 1-3")
+		}
 	case 'M': // maintain current altitude
-		res = p.DoHeight(p.height)
+		return p.DoHeight(p.height)
 	case 'P': // proceed current heading
-		res = p.DoTurn(0)
+		return p.DoTurn(0)
+	case 'D': // direct to entrypoint, airport, navaid or board cell
+		if c.target != nil {
+			return p.DoDirectTo(c.target)
+		}
+		fix := g.board.FindFix(c.fix)
+		if fix == nil {
+			return fmt.Errorf("unknown fix %s", c.fix)
+		}
+		return p.DoDirectTo(fix)
 	case 'H': // hold at navaid
-		res = p.DoHold()
+		return p.DoHold(c.at, false, 0)
+	case 'W': // holding pattern
+		return p.DoHold(c.at, c.right, c.arg)
+	case 'C': // cancel conditional commands
+		p.conditions = nil
+		return nil
 	case 'K': // keep position
-		res = p.DoKeep()
-	case '%', '=':
-		res = p.TurnAtNavaid(c.command)
+		return p.DoKeep()
+	case 'T': // approach airport
+		return p.TurnAtNavaid(c.fix, c.at)
 	default:
 		panic("should not happen")
 	}
-
-	if res {
-		return "Roger"
-	} else {
-		return "Unable"
-	}
 }
 
 type CommandInterpreter struct {
-	buf   string
-	last  string
-	reply string
+	board *Board       // fix names; nil: fixes are single characters
+	keys  *KeyBindings // command keys; nil: the player's key bindings
+
+	buf    string
+	cursor int // in runes
+	last   string
+	reply  string
+
+	history     []HistoryEntry
+	history_pos int // recalled entry counted from the end; 0: none
 
 	delayed_commands []*Command
+	editing          *Command // delayed command in the buffer; applied on Submit
 
 	last_commanded_plane *Plane
 }
 
+func (ci *CommandInterpreter) bindings() *KeyBindings {
+	if ci.keys == nil {
+		return key_bindings
+	}
+	return ci.keys
+}
+
 func (ci *CommandInterpreter) KeyPressed(g *GameState, key rune) {
-	ci.buf += string(key)
+	ci.insert(key)
+
+	if strings.HasPrefix(ci.buf, CHAIN_PREFIX) || ci.editing != nil {
+		// chains and edits are applied on Submit
+		return
+	}
+
+	if key == REPEAT_KEY {
+		ci.repeat_last()
+	}
 
-	cmd := ci.parse_command(ci.buf)
+	cmd, used := ci.parse_command(ci.buf)
 
 	if cmd == nil {
 		// incomplete
 		return
 	}
+	if used < len(ci.buf) {
+		// trailing input after an edit
+		cmd.valid = false
+	}
+	ci.run(g, cmd)
+}
 
+// the edited delayed command is replaced by cmd if cmd is applied or
+// queued, and restored otherwise
+func (ci *CommandInterpreter) run(g *GameState, cmd *Command) {
 	ci.last = ci.buf
-	ci.buf = ""
+	ci.set_buffer("")
+
+	g.Record(CONTROLLER, ci.last)
+
+	if g.tutor != nil && !g.tutor.Accept(cmd) {
+		ci.restore_edit()
+		ci.reply = "(tutorial)"
+		if cmd.Recallable() {
+			ci.add_history(ci.last, ci.reply)
+		}
+		return
+	}
 
-	if cmd.delayed > 0 {
+	ok := true
+	if cmd.delayed > 0 && cmd.valid {
 		ci.delayed_commands = append(ci.delayed_commands, cmd)
+		ci.reply = "(pending)"
 	} else {
 		plane := g.FindPlane(cmd.callsign)
-		ci.reply = cmd.Apply(plane)
+		ci.reply, ok = cmd.Apply(g, plane)
 		ci.last_commanded_plane = plane
+		if plane != nil {
+			g.RecordPlane(plane, ci.reply)
+		}
+	}
+	if ok {
+		ci.editing = nil
+	} else {
+		ci.restore_edit()
+	}
+	if cmd.Recallable() {
+		ci.add_history(ci.last, ci.reply)
+	}
+
+	if g.tutor != nil && ok {
+		g.tutor.CommandDone(g)
 	}
 }
 
 func (ci *CommandInterpreter) Tick(g *GameState) {
 	ci.last_commanded_plane = nil
 
-	// TODO: old commands stay in delayed_commands with delayed < 0
+	pending := make([]*Command, 0, len(ci.delayed_commands))
 	for _, cmd := range ci.delayed_commands {
 		cmd.delayed -= 1
-		if cmd.delayed == 0 {
-			plane := g.FindPlane(cmd.callsign)
-			_ = cmd.Apply(plane) // apply silently
+		if cmd.delayed > 0 {
+			pending = append(pending, cmd)
+			continue
 		}
+
+		// the reply goes to the history and transcript; the status line
+		// keeps the reply to the last command typed
+		plane := g.FindPlane(cmd.callsign)
+		g.Record(CONTROLLER, "(delayed) "+cmd.String())
+
+		reply, _ := cmd.Apply(g, plane)
+		ci.last_commanded_plane = plane
+		if cmd.Recallable() {
+			ci.add_history(cmd.String(), "(delayed) "+reply)
+		}
+		if plane != nil {
+			g.RecordPlane(plane, reply)
+		}
+	}
+	ci.delayed_commands = pending
+}
+
+func (ci *CommandInterpreter) CancelDelayed(n int) {
+	if n < 0 || n >= len(ci.delayed_commands) {
+		return
+	}
+	ci.delayed_commands = append(ci.delayed_commands[:n], ci.delayed_commands[n+1:]...)
+}
+
+// move a delayed command to the input buffer for editing. Submit applies
+// the edited command, Clear puts the original one back.
+func (ci *CommandInterpreter) EditDelayed(n int) {
+	if n < 0 || n >= len(ci.delayed_commands) {
+		return
+	}
+	ci.Clear()
+	ci.editing = ci.delayed_commands[n]
+	ci.set_buffer(ci.editing.String())
+	ci.CancelDelayed(n)
+}
+
+// apply a complete command in the buffer, clear otherwise
+func (ci *CommandInterpreter) Submit(g *GameState) {
+	if strings.HasPrefix(ci.buf, CHAIN_PREFIX) {
+		ci.run_chain(g)
+		return
+	}
+
+	cmd, used := ci.parse(ci.buf, true)
+	if ci.editing != nil && (cmd == nil || !cmd.valid || used < len(ci.buf)) {
+		// keep editing
+		return
+	}
+	if cmd == nil {
+		ci.Clear()
+		return
+	}
+	if used < len(ci.buf) {
+		cmd.valid = false
+	}
+	ci.run(g, cmd)
+}
+
+// apply all commands of a chain or none of them if any is invalid or unable.
+// A delayed command being edited stays in the list.
+func (ci *CommandInterpreter) run_chain(g *GameState) {
+	ci.last = ci.buf
+	ci.set_buffer("")
+	ci.restore_edit()
+	ci.last_commanded_plane = nil
+	defer func() { ci.add_history(ci.last, ci.reply) }()
+	g.Record(CONTROLLER, ci.last)
+
+	if g.tutor != nil {
+		ci.reply = "no chains in the tutorial"
+		return
+	}
+
+	cmds := ci.parse_chain(ci.last)
+	if cmds == nil {
+		ci.reply = "--- Say Again? ---"
+		return
+	}
+
+	// dry run on a copy of the game. The holding levels are checked once
+	// the whole chain is applied, so planes can swap levels.
+	dry := *g
+	dry.planes = make([]*Plane, len(g.planes))
+	saved := make(map[*Plane]Plane)
+	for n, plane := range g.planes {
+		p := *plane
+		p.conditions = append([]*Command(nil), plane.conditions...)
+		dry.planes[n] = &p
+		saved[plane] = p
+	}
+	for _, cmd := range cmds {
+		if dry.FindPlane(cmd.callsign) == nil {
+			ci.reply = fmt.Sprintf("%c: ---------", cmd.callsign)
+			return
+		}
+		reply, ok := cmd.execute(&dry, dry.FindPlane(cmd.callsign), false)
+		if !ok {
+			ci.reply = fmt.Sprintf("%c %s: %s", cmd.callsign, cmd.Instruction(), reply)
+			return
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.check_level(&dry, dry.FindPlane(cmd.callsign)); err != nil {
+			ci.reply = fmt.Sprintf("%c %s: Unable, %s", cmd.callsign, cmd.Instruction(), err)
+			return
+		}
+	}
+
+	replies := make([]string, len(cmds))
+	for n, cmd := range cmds {
+		var ok bool
+		replies[n], ok = cmd.execute(g, g.FindPlane(cmd.callsign), false)
+		if !ok {
+			// roll back the commands applied so far
+			for _, cmd := range cmds[:n+1] {
+				plane := g.FindPlane(cmd.callsign)
+				*plane = saved[plane]
+			}
+			ci.reply = fmt.Sprintf("%c %s: %s", cmd.callsign, cmd.Instruction(), replies[n])
+			return
+		}
+	}
+
+	var last rune
+	for n, cmd := range cmds {
+		plane := g.FindPlane(cmd.callsign)
+		reply := replies[n]
+		g.RecordPlane(plane, cmd.Instruction()+": "+reply)
+		if cmd.callsign != last {
+			reply = fmt.Sprintf("%c: %s", cmd.callsign, reply)
+			last = cmd.callsign
+		}
+		replies[n] = reply
+		ci.last_commanded_plane = plane
+	}
+	ci.reply = strings.Join(replies, ", ")
+}
+
+// parse +<callsigns>:<commands>[;<callsigns>:<commands>...]
+// Returns nil if any part is invalid.
+func (ci *CommandInterpreter) parse_chain(s string) []*Command {
+	cmds := make([]*Command, 0)
+
+	for _, group := range strings.Split(strings.TrimPrefix(s, CHAIN_PREFIX), ";") {
+		parts := strings.SplitN(group, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil
+		}
+
+		for _, callsign := range parts[0] {
+			if callsign < 'A' || callsign > 'Z' {
+				return nil
+			}
+
+			rest := parts[1]
+			for rest != "" {
+				cmd, used := ci.parse(string(callsign)+rest, true)
+				if cmd == nil || !cmd.valid || cmd.delayed > 0 {
+					return nil
+				}
+				cmds = append(cmds, cmd)
+				rest = rest[used-1:]
+			}
+		}
+	}
+	return cmds
+}
+
+// put the delayed command being edited back in the list
+func (ci *CommandInterpreter) restore_edit() {
+	if ci.editing != nil {
+		ci.delayed_commands = append(ci.delayed_commands, ci.editing)
+		ci.editing = nil
 	}
 }
 
 func (ci *CommandInterpreter) Clear() {
-	ci.buf = ""
+	ci.restore_edit()
+	ci.set_buffer("")
 	ci.last = ""
 	ci.reply = ""
 }
 
 func (ci CommandInterpreter) StatusLine() string {
-	if len(ci.buf) > 0 {
+	if ci.editing != nil {
+		return ci.buf + "   (edit, Enter applies)"
+	} else if len(ci.buf) > 0 {
 		return ci.buf
 	} else {
 		return fmt.Sprintf("%s %s", ci.last, ci.reply)
 	}
 }
 
-func (ci *CommandInterpreter) parse_command(s string) *Command {
+// fix names allowed after D, T and @; nil: any single character
+func (ci *CommandInterpreter) fix_names(command rune) []string {
+	if ci.board == nil {
+		return nil
+	}
+	names := ci.board.FixNames(command == 'T')
+	if command == '@' {
+		names = append(names, "*")
+	}
+	return names
+}
+
+// names starting with ident and whether ident is a name itself
+func match_fix(names []string, ident string) ([]string, bool) {
+	if names == nil {
+		if utf8.RuneCountInString(ident) == 1 {
+			return []string{ident}, true
+		}
+		return nil, false
+	}
+	matches := make([]string, 0)
+	exact := false
+	for _, name := range names {
+		if strings.HasPrefix(name, ident) {
+			matches = append(matches, name)
+			exact = exact || name == ident
+		}
+	}
+	return matches, exact
+}
+
+// parse a single command. Returns nil if incomplete and the number of bytes used.
+//
+// @<fix> has two meanings: before H, W or T a navaid letter names the
+// navaid where the plane holds or turns (B@KH). Before any other command,
+// or with a fix that is not a navaid letter (B@LAXA3, B@*H), it is a
+// condition and the command is applied once the plane reaches the fix.
+func (ci *CommandInterpreter) parse_command(s string) (*Command, int) {
+	return ci.parse(s, false)
+}
+
+// Fix names are complete once a single name matches. final: s is the
+// whole input, so a fix name at its end is complete even if a longer
+// name starts with it.
+func (ci *CommandInterpreter) parse(s string, final bool) (*Command, int) {
 	var cmd Command
 	var state int
+	var at, ident string // fix names in states 4 and 3
+
+	done := func() *Command {
+		cmd.valid = true
+		if state == 3 {
+			cmd.fix = ident
+		}
+		if len(at) == 1 && at[0] >= 'A' && at[0] <= 'Z' &&
+			strings.ContainsRune(COMMANDS_AT_NAVAID, cmd.command) {
+			cmd.at = rune(at[0])
+		} else if at != "" {
+			// everything else at a fix is a conditional command
+			cmd.cond = &Condition{fix: at}
+		}
+		return &cmd
+	}
+
+	for n, char := range s {
+		used := n + utf8.RuneLen(char)
+
+		if state == 4 {
+			// fix name as long as it matches, then the command
+			names := ci.fix_names('@')
+			if matches, _ := match_fix(names, at+string(char)); len(matches) == 1 {
+				at = matches[0]
+				state = 5
+				continue
+			} else if len(matches) > 1 {
+				at += string(char)
+				continue
+			}
+			if _, exact := match_fix(names, at); !exact {
+				return &cmd, used
+			}
+			state = 5
+		}
+
+		// state 1: command; state 5: command after condition
+		is_command := state == 1 || state == 5
+		if is_command {
+			char = ci.bindings().Command(char)
+		}
 
-	for _, char := range s {
 		switch {
 		case state == 0 && char == '.':
 			cmd.delayed += 1
 		case state == 0 && char >= 'A' && char <= 'Z':
 			state = 1
 			cmd.callsign = char
-		case state == 1 && strings.ContainsRune(COMMANDS_WITHOUT_ARG, char):
+		case state == 1 && char == '@':
+			state = 4
+		case state == 1 && char == '^':
+			state = 7
+		case state == 7 && char >= '0' && char <= '9':
+			level, _ := strconv.Atoi(string(char))
+			cmd.cond = &Condition{level: level}
+			state = 5
+		case is_command && char == 'W':
+			cmd.command = char
+			state = 6
+		case state == 6 && (char == 'L' || char == 'R'):
+			cmd.right = char == 'R'
+			state = 2
+		case is_command && strings.ContainsRune(AIRPORT_SIGNS, char):
+			// short form of T
+			cmd.command = 'T'
+			cmd.fix = string(char)
+			return done(), used
+		case is_command && strings.ContainsRune(COMMANDS_WITHOUT_ARG, char):
 			cmd.command = char
-			cmd.valid = true
-			return &cmd
-		case state == 1 && strings.ContainsRune(COMMANDS_WITH_ARG, char):
+			return done(), used
+		case is_command && strings.ContainsRune(COMMANDS_WITH_ARG, char):
 			cmd.command = char
 			state = 2
+		case is_command && strings.ContainsRune(COMMANDS_WITH_FIX, char):
+			cmd.command = char
+			state = 3
+		case state == 3:
+			names := ci.fix_names(cmd.command)
+			matches, _ := match_fix(names, ident+string(char))
+			switch {
+			case len(matches) == 1:
+				ident = matches[0]
+				return done(), used
+			case len(matches) > 1:
+				ident += string(char)
+			default:
+				if _, exact := match_fix(names, ident); exact {
+					// char starts the next command of a chain
+					return done(), n
+				}
+				return &cmd, used
+			}
 		case state == 2 && char >= '0' && char <= '9':
 			arg, _ := strconv.Atoi(string(char))
 			cmd.arg = arg
-			cmd.valid = true
-			return &cmd
+			return done(), used
 		default:
 			// valid == false
-			return &cmd
+			return &cmd, used
 		}
 	}
-	return nil
+
+	if _, exact := match_fix(ci.fix_names(cmd.command), ident); final && state == 3 && exact {
+		return done(), len(s)
+	}
+	return nil, len(s)
 }
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func testGame() *GameState {
+	g := &GameState{
+		rules: &DEFAULT_RULES,
+		board: DEFAULT_BOARD,
+		clock: 20 * Minutes,
+		voice: NullVoice{},
+		ci:    CommandInterpreter{board: DEFAULT_BOARD},
+	}
+	for _, callsign := range "BC" {
+		g.planes = append(g.planes, &Plane{
+			callsign:    callsign,
+			typ:         &PLANE_TYPE_JET,
+			entry:       DEFAULT_BOARD.entrypoints["0"],
+			exit:        DEFAULT_BOARD.entrypoints["9"],
+			state:       StateFlying,
+			Position:    Position{5, 5},
+			Direction:   DIR_E,
+			height:      5,
+			want_height: 5,
+		})
+	}
+	return g
+}
+
+func TestParseCommand(t *testing.T) {
+	var ci CommandInterpreter
+
+	test := func(s string, valid bool, instruction string) {
+		cmd, _ := ci.parse_command(s)
+		if cmd == nil {
+			t.Error(s, "incomplete")
+			return
+		}
+		if cmd.valid != valid {
+			t.Error(s, "valid:", cmd.valid)
+		}
+		if valid && cmd.Instruction() != instruction {
+			t.Error(s, instruction, "!=", cmd.Instruction())
+		}
+	}
+	test("BR2", true, "R2")
+	test("..BA4", true, "A4")
+	test("BD%", true, "D%")
+	test("BWR3", true, "WR3")
+	test("B@WH", true, "@WH")
+	test("B@7A3", true, "@7A3")
+	test("B^5L1", true, "^5L1")
+	test("BX", false, "")
+	test("B^^", false, "")
+
+	for _, s := range []string{"", "B", "BR", "B@W", "B^5", "BW"} {
+		if cmd, _ := ci.parse_command(s); cmd != nil {
+			t.Error(s, "should be incomplete")
+		}
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	var ci CommandInterpreter
+
+	cmds := ci.parse_chain("+BC:R2A4;D:L1")
+	if len(cmds) != 5 {
+		t.Fatal(cmds)
+	}
+	expected := []string{"BR2", "BA4", "CR2", "CA4", "DL1"}
+	for n, cmd := range cmds {
+		if cmd.String() != expected[n] {
+			t.Error(n, cmd, "!=", expected[n])
+		}
+	}
+
+	for _, s := range []string{"+", "+B", "+B:", "+:R2", "+B:R", "+B:RX", "+B:R2;", "+B:.R2"} {
+		if cmds := ci.parse_chain(s); cmds != nil {
+			t.Error(s, "should be invalid", cmds)
+		}
+	}
+}
+
+func TestChainIsAtomic(t *testing.T) {
+	g := testGame()
+
+	// turning left 9 is unable: nothing is applied
+	g.ci.buf = "+B:A3;C:L9"
+	g.ci.Submit(g)
+	if g.FindPlane('B').want_height != 5 {
+		t.Error("partial chain applied", g.ci.reply)
+	}
+
+	g.ci.buf = "+BC:A3R2"
+	g.ci.Submit(g)
+	for _, p := range g.planes {
+		if p.want_height != 3 || p.want_turn != 2 {
+			t.Error("chain not applied", p.State(), g.ci.reply)
+		}
+	}
+}
+
+func TestChainInHold(t *testing.T) {
+	g := testGame()
+	for n, p := range g.planes {
+		p.is_holding = true
+		p.hold_pos = Position{9, 10}
+		p.height = 2 + 2*n
+		p.want_height = p.height
+	}
+
+	// both planes to the same level: neither is cleared
+	g.ci.buf = "+BC:A3"
+	g.ci.Submit(g)
+	if g.FindPlane('B').want_height != 2 || g.FindPlane('C').want_height != 4 {
+		t.Error("partial chain applied", g.ci.reply)
+	}
+
+	// swap the levels
+	g.ci.buf = "+B:A4;C:A2"
+	g.ci.Submit(g)
+	if g.FindPlane('B').want_height != 4 || g.FindPlane('C').want_height != 2 {
+		t.Error("swap rejected", g.ci.reply)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	g := testGame()
+	for _, key := range "BR2" {
+		g.ci.KeyPressed(g, key)
+	}
+
+	g.ci.Recall(1)
+	if g.ci.buf != "BR2" {
+		t.Error("recall:", g.ci.buf)
+	}
+
+	// replace callsign of the recalled command
+	g.ci.CursorHome()
+	g.ci.Delete()
+	g.ci.KeyPressed(g, 'C')
+	if g.ci.last != "CR2" || g.FindPlane('C').want_turn != 2 {
+		t.Error("edit:", g.ci.last, g.ci.reply)
+	}
+
+	g.ci.KeyPressed(g, 'B')
+	g.ci.KeyPressed(g, REPEAT_KEY)
+	if g.ci.last != "BR2" || g.ci.reply != "turning right heading S" {
+		t.Error("repeat:", g.ci.last, g.ci.reply)
+	}
+
+	if len(g.ci.Scrollback(10)) != 3 {
+		t.Error(g.ci.Scrollback(10))
+	}
+
+	// each aircraft repeats its own command; others the newest one
+	for _, key := range "CL1" {
+		g.ci.KeyPressed(g, key)
+	}
+	for _, key := range "B" + string(REPEAT_KEY) {
+		g.ci.KeyPressed(g, key)
+	}
+	if g.ci.last != "BR2" {
+		t.Error("repeat for B:", g.ci.last)
+	}
+	for _, key := range "X" + string(REPEAT_KEY) {
+		g.ci.KeyPressed(g, key)
+	}
+	if g.ci.last != "XR2" {
+		t.Error("repeat for X:", g.ci.last)
+	}
+
+	// clicks on a fix are recorded by its name, other cells not at all
+	g.Select(g.FindPlane('B'))
+	g.Click(Position{15, 5})
+	g.ci.Recall(1)
+	if g.ci.buf != "BD%" {
+		t.Error("recall click on fix:", g.ci.buf)
+	}
+	g.Submit()
+	if g.ci.reply != g.ci.Scrollback(1)[0].reply || strings.Contains(g.ci.reply, "Say Again") {
+		t.Error("recalled click:", g.ci.reply)
+	}
+	g.Select(g.FindPlane('B'))
+	g.Click(Position{7, 3})
+	if g.ci.last != "BD7/3" || g.ci.Scrollback(1)[0].command != "BD%" {
+		t.Error("click on cell:", g.ci.last, g.ci.Scrollback(1))
+	}
+}
+
+func TestKeyBindings(t *testing.T) {
+	read := func(conf string) (*KeyBindings, error) {
+		filename := t.TempDir() + "/keys.conf"
+		if err := os.WriteFile(filename, []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return ReadKeyBindings(filename)
+	}
+
+	kb, err := read("# remapped\nleft = U\nright = Y\npause = F1 !\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kb.ActionKey(ActionPause) != "F1" || kb.ActionKey(ActionHelp) != "?" {
+		t.Error(kb.actions)
+	}
+
+	defer func() { key_bindings = &DEFAULT_KEY_BINDINGS }()
+	key_bindings = kb
+
+	var ci CommandInterpreter
+	if cmd, _ := ci.parse_command("BU2"); cmd == nil || cmd.command != 'L' || cmd.Instruction() != "U2" {
+		t.Error("remapped command:", cmd)
+	}
+	if cmd, _ := ci.parse_command("BL2"); cmd == nil || cmd.valid {
+		t.Error("moved command still valid:", cmd)
+	}
+
+	// T was free before the approach command
+	if _, err := read("left = T"); err == nil || !strings.Contains(err.Error(), "T is reserved for approach") {
+		t.Error("left = T:", err)
+	}
+	if _, err := read("left = T\napproach = U"); err != nil {
+		t.Error(err)
+	}
+
+	for _, conf := range []string{"left = R", "advance = ?", "pause = p", "help = Up", "help = .", "tracks = *", "tracks = Ctrl+H", "foo = F1", "left = U\nright = U"} {
+		if _, err := read(conf); err == nil {
+			t.Error(conf, "should be invalid")
+		}
+	}
+}
+
+func TestParseFixNames(t *testing.T) {
+	b, err := ReadBoard(NAMED_BOARD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci := CommandInterpreter{board: b}
+
+	test := func(s string, final bool, instruction string) {
+		cmd, _ := ci.parse(s, final)
+		if cmd == nil {
+			t.Error(s, "incomplete")
+			return
+		}
+		if !cmd.valid || cmd.Instruction() != instruction {
+			t.Error(s, cmd.valid, cmd.Instruction(), "!=", instruction)
+		}
+	}
+	test("BDLAX", false, "DLAX")
+	test("BDLA", true, "DLA")
+	test("BTKJ", false, "TKJFK")
+	test("B%", false, "%")
+	test("B@LAXR2", false, "@LAXR2")
+	test("B@KLA3", false, "@KLGAA3")
+
+	for _, s := range []string{"BDLA", "BTK", "BDK", "B@LA"} {
+		if cmd, _ := ci.parse_command(s); cmd != nil {
+			t.Error(s, "should be incomplete")
+		}
+	}
+	for _, s := range []string{"BTLA", "BDQ", "B@XR2"} {
+		if cmd, _ := ci.parse_command(s); cmd == nil || cmd.valid {
+			t.Error(s, "should be invalid")
+		}
+	}
+
+	cmds := ci.parse_chain("+B:DLAR2")
+	if len(cmds) != 2 || cmds[0].fix != "LA" {
+		t.Error(cmds)
+	}
+}
+
+func TestDelayedCommands(t *testing.T) {
+	g := testGame()
+	for _, s := range []string{"..BA3", "...CR2"} {
+		g.ci.set_buffer(s)
+		g.ci.Submit(g)
+	}
+	g.ci.set_buffer("BL1")
+	g.ci.Submit(g)
+
+	// cancel
+	g.ci.CancelDelayed(1)
+	if len(g.ci.delayed_commands) != 1 || g.ci.delayed_commands[0].String() != "..BA3" {
+		t.Fatal("cancel:", g.ci.delayed_commands)
+	}
+
+	// keys edit the command until Enter
+	g.ci.EditDelayed(0)
+	g.ci.Backspace()
+	g.ci.KeyPressed(g, '4')
+	if g.ci.buf != "..BA4" || len(g.ci.delayed_commands) != 0 {
+		t.Fatal("edit:", g.ci.buf, g.ci.delayed_commands)
+	}
+	g.ci.Submit(g)
+	if len(g.ci.delayed_commands) != 1 || g.ci.delayed_commands[0].String() != "..BA4" {
+		t.Fatal("edit not applied:", g.ci.delayed_commands)
+	}
+
+	// Clear keeps the original command
+	g.ci.EditDelayed(0)
+	g.ci.Backspace()
+	g.ci.Clear()
+	if len(g.ci.delayed_commands) != 1 || g.ci.delayed_commands[0].String() != "..BA4" {
+		t.Fatal("edit cancelled:", g.ci.delayed_commands)
+	}
+
+	// commands that do not replace the edited one keep it
+	for _, s := range []string{"+C:R1", "BA9"} {
+		g.ci.EditDelayed(0)
+		g.ci.set_buffer(s)
+		g.ci.Submit(g)
+		if len(g.ci.delayed_commands) != 1 || g.ci.delayed_commands[0].String() != "..BA4" || g.ci.editing != nil {
+			t.Fatal(s, "lost the edited command:", g.ci.delayed_commands, g.ci.reply)
+		}
+	}
+
+	// fired commands keep the reply of the last command typed
+	g.ci.set_buffer("CL1")
+	g.ci.Submit(g)
+	reply := g.ci.reply
+	g.ci.Tick(g)
+	g.ci.Tick(g)
+	if g.FindPlane('B').want_height != 4 || g.ci.last != "CL1" || g.ci.reply != reply {
+		t.Error("fired:", g.ci.last, g.ci.reply)
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	ci := CommandInterpreter{board: DEFAULT_BOARD}
+	for _, c := range []struct {
+		s, at, cond string
+	}{
+		{"B@EH", "E", ""},
+		{"B@EWR2", "E", ""},
+		{"B@E%", "E", ""},
+		{"B@EA3", "", "@E"},
+		{"B@5L1", "", "@5"},
+		{"B@*H", "", "@*"},
+		{"B^3V1", "", "^3"},
+	} {
+		cmd, _ := ci.parse(c.s, true)
+		if cmd == nil || !cmd.valid {
+			t.Error(c.s, "invalid")
+			continue
+		}
+		cond := ""
+		if cmd.cond != nil {
+			cond = cmd.cond.String()
+		}
+		at := ""
+		if cmd.at != 0 {
+			at = string(cmd.at)
+		}
+		if at != c.at || cond != c.cond || cmd.String() != c.s {
+			t.Error(c.s, "at", at, "condition", cond, cmd)
+		}
+	}
+}
+
+func TestConditionalCommands(t *testing.T) {
+	g := testGame()
+	b := g.FindPlane('B')
+
+	// the command is checked when it is queued
+	for _, s := range []string{"B^3V9", "B^3L5", "B@5K"} {
+		g.ci.set_buffer(s)
+		g.ci.Submit(g)
+		if len(b.conditions) != 0 {
+			t.Error(s, "queued:", g.ci.reply)
+		}
+	}
+
+	for _, s := range []string{"B^4L1", "B^5A3", "B^3R2"} {
+		g.ci.set_buffer(s)
+		g.ci.Submit(g)
+	}
+	if len(b.conditions) != 3 {
+		t.Fatal("not queued:", g.ci.reply)
+	}
+	b.ApplyConditions(g)
+	if b.want_height != 3 || len(b.conditions) != 2 {
+		t.Error("^5 not applied", b.want_height, len(b.conditions))
+	}
+
+	// a conditional cancel drops the conditions after it as well
+	b.conditions = nil
+	for _, s := range []string{"B^4L1", "B^5C", "B^5A3", "B^3R2"} {
+		g.ci.set_buffer(s)
+		g.ci.Submit(g)
+	}
+	b.want_height = 5
+	b.ApplyConditions(g)
+	if len(b.conditions) != 0 || b.want_height != 5 {
+		t.Error("not cancelled", len(b.conditions), b.want_height)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// part of the game with its share of the traffic
+type DensityPhase struct {
+	weight int // relative number of planes starting in this phase
+
+	// route weight factors in percent; 100 keeps the board weights
+	arrivals   int // routes to an airport
+	departures int // routes from an airport
+}
+
+// traffic over the game time: consecutive phases of equal length
+type DensityProfile struct {
+	name   string
+	phases []DensityPhase
+}
+
+var (
+	PROFILE_FLAT = DensityProfile{
+		name:   "Flat",
+		phases: []DensityPhase{{1, 100, 100}},
+	}
+
+	PROFILE_RAMP_UP = DensityProfile{
+		name: "Ramp-up",
+		phases: []DensityPhase{
+			{1, 100, 100}, {2, 100, 100}, {3, 100, 100}, {4, 100, 100}, {5, 100, 100}, {6, 100, 100},
+		},
+	}
+
+	PROFILE_RUSH_HOURS = DensityProfile{
+		name: "Rush Hours",
+		phases: []DensityPhase{
+			{1, 100, 100}, {3, 100, 100}, {6, 100, 100}, {3, 100, 100}, {1, 100, 100},
+			{1, 100, 100}, {3, 100, 100}, {6, 100, 100}, {3, 100, 100}, {1, 100, 100},
+		},
+	}
+
+	// waves of arrivals, each followed by departures
+	PROFILE_ARRIVAL_BANKS = DensityProfile{
+		name: "Arrival Banks",
+		phases: []DensityPhase{
+			{2, 100, 100}, {4, 500, 25}, {3, 25, 500},
+			{2, 100, 100}, {4, 500, 25}, {3, 25, 500},
+		},
+	}
+)
+
+const PREVIEW_LEVELS = " .:-=#"
+
+func (d *Difficulty) Profile() *DensityProfile {
+	if d.profile == nil {
+		return &PROFILE_FLAT
+	}
+	return d.profile
+}
+
+// random phase, weighted
+func (dp *DensityProfile) choose(r *rand.Rand) int {
+	if len(dp.phases) == 1 {
+		return 0
+	}
+
+	count := 0
+	for _, ph := range dp.phases {
+		count += ph.weight
+	}
+	val := r.Intn(count)
+	for nr, ph := range dp.phases {
+		val -= ph.weight
+		if val < 0 {
+			return nr
+		}
+	}
+	panic("should not happen")
+}
+
+// random start time in the phase; planes start between first and last
+func (dp *DensityProfile) start(r *rand.Rand, phase int, first, last Ticks) Ticks {
+	if len(dp.phases) == 1 {
+		return Ticks(RandRange(r, int(last), int(first)))
+	}
+
+	// the clock counts down from first
+	span := int(first - last)
+	n := len(dp.phases)
+	from, to := span*phase/n, span*(phase+1)/n
+	return first - Ticks(RandRange(r, from, to))
+}
+
+// board routes with the weights of the phase
+func (ph DensityPhase) routes(b *Board) []Route {
+	if ph.arrivals == 100 && ph.departures == 100 {
+		return b.routes
+	}
+
+	res := make([]Route, len(b.routes))
+	count := 0
+	for nr, route := range b.routes {
+		factor := 100
+		if b.entrypoints[route.exit].is_airport {
+			factor = ph.arrivals
+		} else if b.entrypoints[route.entry].is_airport {
+			factor = ph.departures
+		}
+		res[nr] = route
+		res[nr].weight = route.weight * factor
+		count += res[nr].weight
+	}
+
+	if count == 0 {
+		return b.routes
+	}
+	return res
+}
+
+// density over the game time, one character per column
+func (dp *DensityProfile) Preview(width int) string {
+	max := 0
+	for _, ph := range dp.phases {
+		max = Max(max, ph.weight)
+	}
+
+	var res strings.Builder
+	for x := 0; x < width; x++ {
+		ph := dp.phases[x*len(dp.phases)/width]
+		level := 0
+		if max > 0 {
+			level = (ph.weight*(len(PREVIEW_LEVELS)-1) + max - 1) / max
+		}
+		res.WriteByte(PREVIEW_LEVELS[level])
+	}
+	return res.String()
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestDensityProfiles(t *testing.T) {
+	for _, d := range DIFFICULTIES {
+		dp := d.Profile()
+		n := len(dp.phases)
+		span := d.duration - DEFAULT_RULES.last_plane_start
+
+		// planes per phase and arrivals per phase over many games
+		planes := make([]int, n)
+		arrivals := make([]int, n)
+		for seed := int64(0); seed < 20; seed++ {
+			for _, p := range MakePlanes(&DEFAULT_RULES, DEFAULT_BOARD, d, seed) {
+				if p.start < DEFAULT_RULES.last_plane_start || p.start > d.duration {
+					t.Fatal(d.name, "start out of range", p.start)
+				}
+				phase := Min(int(d.duration-p.start)*n/int(span), n-1)
+				planes[phase] += 1
+				if p.exit.is_airport {
+					arrivals[phase] += 1
+				}
+			}
+		}
+
+		for nr := 1; nr < n; nr++ {
+			prev, ph := dp.phases[nr-1], dp.phases[nr]
+			if ph.weight >= 2*prev.weight && planes[nr] <= planes[nr-1] {
+				t.Error(d.name, "phase", nr, "not denser:", planes)
+			}
+			if ph.arrivals >= 4*prev.arrivals && arrivals[nr] <= arrivals[nr-1] {
+				t.Error(d.name, "phase", nr, "no arrival bank:", arrivals)
+			}
+		}
+	}
+
+	if s := PROFILE_RAMP_UP.Preview(12); s != "..::--==####" {
+		t.Error("preview", s)
+	}
+	if s := PROFILE_FLAT.Preview(4); s != "####" {
+		t.Error("preview", s)
+	}
+}
@@ -10,7 +10,17 @@ const (
 	BORDER_V = 1
 )
 
-func DrawWindow(title string, footer string, lines []string, colors []termbox.Attribute) {
+// position of the lines of a window on the screen
+type WindowLayout struct {
+	left, right int
+	top, bottom int
+
+	contw   int
+	rows    int
+	max_len int
+}
+
+func NewWindowLayout(title string, footer string, lines []string) WindowLayout {
 	termw, termh := termbox.Size()
 	contw, conth := termw-2*BORDER_H, termh-2*BORDER_V
 
@@ -35,9 +45,41 @@ func DrawWindow(title string, footer string, lines []string, colors []termbox.At
 	}
 
 	left := Max((termw-contw-2*BORDER_H)/2, 0)
-	right := left + contw + BORDER_H + 1
 	top := Max((termh-conth-2*BORDER_V)/2, 0)
-	bottom := top + conth + BORDER_V
+
+	return WindowLayout{
+		left:   left,
+		right:  left + contw + BORDER_H + 1,
+		top:    top,
+		bottom: top + conth + BORDER_V,
+
+		contw:   contw,
+		rows:    rows,
+		max_len: max_len,
+	}
+}
+
+// screen position of a line
+func (wl WindowLayout) LinePosition(n int) (int, int) {
+	return wl.left + BORDER_H + (n/wl.rows)*(wl.max_len+1),
+		wl.top + BORDER_V + (n % wl.rows)
+}
+
+// line at a screen position; -1 if none
+func (wl WindowLayout) LineAt(x, y int, num_lines int) int {
+	for n := 0; n < num_lines; n++ {
+		lx, ly := wl.LinePosition(n)
+		if y == ly && x >= lx && x < lx+wl.max_len {
+			return n
+		}
+	}
+	return -1
+}
+
+func DrawWindow(title string, footer string, lines []string, colors []termbox.Attribute) {
+	wl := NewWindowLayout(title, footer, lines)
+	left, right, top, bottom := wl.left, wl.right, wl.top, wl.bottom
+	contw := wl.contw
 
 	// draw border
 	for x := left; x <= right; x += 1 {
@@ -62,22 +104,18 @@ func DrawWindow(title string, footer string, lines []string, colors []termbox.At
 		print(left+contw/2-len(footer)/2, bottom, " ", footer, " ")
 	}
 
-	left += BORDER_H
-	top += BORDER_V
-
 	for pos, line := range lines {
 		color := termbox.ColorDefault
 		if pos < len(colors) {
 			color = colors[pos]
 		}
-		printC(
-			left+(pos/rows)*(max_len+1),
-			top+(pos%rows),
-			color, Pad(max_len, line, ""))
+		x, y := wl.LinePosition(pos)
+		printC(x, y, color, Pad(wl.max_len, line, ""))
 	}
 }
 
-func DrawPlanes(game *GameState) bool {
+func planes_window(game *GameState) ([]*Plane, []string, []termbox.Attribute) {
+	planes := make([]*Plane, 0, len(game.planes))
 	lines := make([]string, 0, len(game.planes))
 	colors := make([]termbox.Attribute, 0, len(game.planes))
 
@@ -86,17 +124,22 @@ func DrawPlanes(game *GameState) bool {
 			continue
 		}
 
+		planes = append(planes, p)
 		lines = append(lines, p.String())
 		switch {
 		case p.IsActive():
 			colors = append(colors, termbox.ColorDefault)
 		case p.IsDone():
-			colors = append(colors, termbox.ColorGreen)
+			colors = append(colors, theme.done)
 		default:
-			colors = append(colors, termbox.ColorBlue)
+			colors = append(colors, theme.pending)
 		}
 	}
+	return planes, lines, colors
+}
 
+func DrawPlanes(game *GameState) bool {
+	_, lines, colors := planes_window(game)
 	if len(lines) == 0 {
 		return false
 	}
@@ -104,11 +147,123 @@ func DrawPlanes(game *GameState) bool {
 	return true
 }
 
+// plane clicked in the planes window; nil if none
+func PlaneInWindow(game *GameState, x, y int) *Plane {
+	planes, lines, _ := planes_window(game)
+	n := NewWindowLayout("Planes", "", lines).LineAt(x, y, len(lines))
+	if n < 0 {
+		return nil
+	}
+	return planes[n]
+}
+
+func DrawDelayed(game *GameState, selected int) bool {
+	cmds := game.ci.delayed_commands
+	if len(cmds) == 0 {
+		return false
+	}
+
+	lines := make([]string, len(cmds))
+	colors := make([]termbox.Attribute, len(cmds))
+	for n, cmd := range cmds {
+		lines[n] = fmt.Sprintf("%c %-8s in %d ticks", cmd.callsign, cmd.Instruction(), cmd.delayed)
+		colors[n] = termbox.ColorDefault
+	}
+	colors[Min(selected, len(cmds)-1)] |= termbox.AttrReverse
+
+	DrawWindow("Delayed Commands", "Del: cancel / Enter: edit", lines, colors)
+	return true
+}
+
+func DelayedKeys(ev termbox.Event, game *GameState, visible *bool, selected *int) {
+	switch ev.Ch {
+	case 0:
+		switch ev.Key {
+		case termbox.KeyEsc,
+			termbox.KeyBackspace, termbox.KeyBackspace2,
+			termbox.KeyTab:
+			*visible = false
+		case termbox.KeyArrowUp:
+			*selected--
+		case termbox.KeyArrowDown:
+			*selected++
+		case termbox.KeyDelete:
+			game.ci.CancelDelayed(*selected)
+		case termbox.KeyEnter:
+			game.ci.EditDelayed(*selected)
+			*visible = false
+		}
+	case 'c', 'C':
+		game.ci.CancelDelayed(*selected)
+	case 'e', 'E':
+		game.ci.EditDelayed(*selected)
+		*visible = false
+	case 'x', 'X', 'q', 'Q':
+		*visible = false
+	}
+
+	*selected = Max(0, Min(*selected, len(game.ci.delayed_commands)-1))
+}
+
+func DrawHistory(game *GameState) bool {
+	entries := game.ci.Scrollback(HISTORY_SIZE)
+	if len(entries) == 0 {
+		return false
+	}
+
+	lines := make([]string, len(entries))
+	for n, e := range entries {
+		lines[n] = fmt.Sprintf("%-10s %s", e.command, e.reply)
+	}
+	DrawWindow("Command History", "", lines, nil)
+	return true
+}
+
+// show the transcript; scroll lines up from the end
+func DrawTranscript(game *GameState, scroll *int) bool {
+	if len(game.transcript) == 0 {
+		return false
+	}
+
+	_, termh := termbox.Size()
+	rows := Max(1, termh-2*BORDER_V-2)
+	*scroll = Max(0, Min(*scroll, len(game.transcript)-rows))
+
+	end := len(game.transcript) - *scroll
+	start := Max(0, end-rows)
+
+	lines := make([]string, 0, rows)
+	for _, e := range game.transcript[start:end] {
+		lines = append(lines, e.String())
+	}
+	DrawWindow("Radio Transcript", "Up / Down / PgUp / PgDn", lines, nil)
+	return true
+}
+
+func TranscriptKeys(ev termbox.Event, visible *bool, scroll *int) {
+	_, termh := termbox.Size()
+	page := Max(1, termh-2*BORDER_V-2)
+
+	switch ev.Key {
+	case termbox.KeyArrowUp:
+		*scroll++
+	case termbox.KeyArrowDown:
+		*scroll--
+	case termbox.KeyPgup:
+		*scroll += page
+	case termbox.KeyPgdn:
+		*scroll -= page
+	default:
+		DialogKeys(ev, visible, nil)
+	}
+}
+
 func DrawHelp(screen uint) {
-	screen = screen % uint(len(HELP))
-	lines := SplitLines(HELP[screen])
+	pages := HelpPages()
+	screen = screen % uint(len(pages))
+	lines := SplitLines(pages[screen])
 	DrawWindow(
-		fmt.Sprintf("Help (page %d of %d)", screen+1, len(HELP)),
+		fmt.Sprintf("Help (page %d of %d)", screen+1, len(pages)),
 		"<- / -> / Space", lines, nil)
 }
 
@@ -139,6 +294,32 @@ func DialogKeys(ev termbox.Event, visible *bool, screen *uint) {
 	}
 }
 
+// "<key> <name>" of the declared fixes, wrapped to width
+func FixLegend(b *Board, width int) []string {
+	keys := b.FixKeys()
+	lines := make([]string, 0)
+	line := ""
+	for _, name := range b.FixNames(false) {
+		key, ok := keys[name]
+		if !ok {
+			continue
+		}
+		entry := fmt.Sprintf("%c %s", key, name)
+		if line != "" && len(line)+2+len(entry) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += "  "
+		}
+		line += entry
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
 func print(x, y int, strings ...string) int {
 	return printC(x, y, termbox.ColorDefault, strings...)
 }
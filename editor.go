@@ -0,0 +1,518 @@
+package main
+
+import (
+	"fmt"
+	termbox "github.com/nsf/termbox-go"
+	"os"
+	"strings"
+)
+
+const EDITOR_PANEL_WIDTH = 32
+
+const (
+	InputNone = iota
+	InputRoute
+	InputName
+	InputEntry   // name of an entrypoint declared at the cursor
+	InputAirport // name of an airport declared at the cursor
+)
+
+var EDITOR_HELP = []string{
+	"arrows: move  0-9: entrypoint  % =: airport  +: turn runway",
+	"*: navaid  A-Z: named navaid  x: nofly  . Del: clear",
+	"Ctrl+E: named entrypoint  Ctrl+A: named airport",
+	"Tab: routes  Ctrl+N: rename  Ctrl+S: save  Esc: quit",
+}
+
+var EDITOR_ROUTE_HELP = []string{
+	"Up/Down: select  a: add  Enter: edit  Del: remove",
+	"+ -: weight  Tab: board  Ctrl+S: save  Esc: quit",
+	"route: <weight>: <entry>-<exit>-<direction> ...",
+}
+
+type Editor struct {
+	board    *Board
+	filename string // "": save to the boards directory
+
+	cursor Position
+	offset Position // top left visible cell
+
+	in_routes bool // keys go to the route panel
+	route     int  // selected route
+
+	input      string
+	input_mode int
+	replace    bool // input replaces the selected route
+
+	message      string
+	modified     bool
+	saved        *Board // copy of the board as last saved; nil: never saved
+	quit_pending bool
+}
+
+func NewBoard(name string, width, height int) *Board {
+	return &Board{
+		name:        name,
+		width:       width,
+		height:      height,
+		entrypoints: make(map[string]*EntryPoint),
+		navaids:     make([]Navaid, 0),
+		routes:      make([]Route, 0),
+		nofly:       make([]Position, 0),
+	}
+}
+
+// remove everything at pos
+func (e *Editor) clear_cell(pos Position) {
+	b := e.board
+	if ep := b.GetEntryPoint(pos); ep != nil {
+		delete(b.entrypoints, ep.name)
+
+		routes := b.routes[:0]
+		for _, r := range b.routes {
+			if r.entry != ep.name && r.exit != ep.name {
+				routes = append(routes, r)
+			}
+		}
+		if len(routes) < len(b.routes) {
+			e.message = "removed routes of " + ep.name
+		}
+		b.routes = routes
+		e.route = Min(e.route, Max(0, len(b.routes)-1))
+	}
+
+	navaids := b.navaids[:0]
+	for _, n := range b.navaids {
+		if n.Position != pos {
+			navaids = append(navaids, n)
+		}
+	}
+	b.navaids = navaids
+
+	nofly := b.nofly[:0]
+	for _, nf := range b.nofly {
+		if nf != pos {
+			nofly = append(nofly, nf)
+		}
+	}
+	b.nofly = nofly
+}
+
+// place or move an entrypoint or airport to the cursor
+func (e *Editor) place_entrypoint(name string, is_airport bool) {
+	b := e.board
+	if ep, ok := b.entrypoints[name]; ok {
+		if ep.Position == e.cursor {
+			return
+		}
+		// move keeps the routes
+		delete(b.entrypoints, name)
+		e.clear_cell(e.cursor)
+		ep.Position = e.cursor
+		b.entrypoints[name] = ep
+		return
+	}
+
+	e.clear_cell(e.cursor)
+	ep := &EntryPoint{name: name, Position: e.cursor, is_airport: is_airport}
+	if is_airport {
+		for _, d := range DIRECTIONS {
+			if b.Contains(e.cursor.Move(d, 1)) {
+				ep.Direction = d
+				break
+			}
+		}
+	}
+	b.entrypoints[name] = ep
+}
+
+func (e *Editor) place_navaid(name rune) {
+	b := e.board
+	e.clear_cell(e.cursor)
+	if name != 0 {
+		for n, navaid := range b.navaids {
+			if navaid.name == name {
+				b.navaids = append(b.navaids[:n], b.navaids[n+1:]...)
+				break
+			}
+		}
+	}
+	b.navaids = append(b.navaids, Navaid{name: name, Position: e.cursor})
+}
+
+func (e *Editor) toggle_nofly() {
+	if e.board.IsNofly(e.cursor) {
+		e.clear_cell(e.cursor)
+	} else {
+		e.clear_cell(e.cursor)
+		e.board.nofly = append(e.board.nofly, e.cursor)
+	}
+}
+
+func (e *Editor) turn_runway() {
+	ep := e.board.GetEntryPoint(e.cursor)
+	if ep == nil || !ep.is_airport {
+		e.message = "no airport at cursor"
+		return
+	}
+	ep.Direction = ep.Direction.Right(1)
+
+	// departures use the runway direction
+	for n, r := range e.board.routes {
+		if r.entry == ep.name {
+			e.board.routes[n].Direction = ep.Direction
+		}
+	}
+}
+
+func route_line(r Route) string {
+	return fmt.Sprintf("%d: %s-%s", r.weight, r, r.Direction)
+}
+
+// apply the route line being typed
+func (e *Editor) enter_routes() {
+	routes, err := e.board.ReadRoutes(e.input)
+	if err != nil {
+		e.message = err.Error()
+		return
+	}
+
+	b := e.board
+	if e.replace && e.route < len(b.routes) {
+		tail := append(routes, b.routes[e.route+1:]...)
+		b.routes = append(b.routes[:e.route], tail...)
+	} else {
+		b.routes = append(b.routes, routes...)
+		e.route = Max(0, len(b.routes)-1)
+	}
+	e.modified = true
+}
+
+func (e *Editor) save() {
+	if err := e.board.Validate(); err != nil {
+		e.message = "cannot save: " + err.Error()
+		return
+	}
+
+	var err error
+	if e.filename != "" {
+		err = os.WriteFile(e.filename, []byte(FormatBoard(e.board)), 0644)
+	} else {
+		e.filename, err = SaveBoard(e.board)
+	}
+	if err != nil {
+		e.message = err.Error()
+		return
+	}
+	e.message = "saved to " + e.filename
+	e.modified = false
+	e.saved = e.board.Copy()
+}
+
+// returns false when the editor is closed
+func (e *Editor) KeyPressed(ev termbox.Event) bool {
+	e.message = ""
+
+	if e.input_mode != InputNone {
+		e.input_keys(ev)
+		return true
+	}
+
+	switch ev.Key {
+	case termbox.KeyEsc:
+		if e.modified && !e.quit_pending {
+			e.message = "unsaved changes; Esc again to quit"
+			e.quit_pending = true
+			return true
+		}
+		return false
+	case termbox.KeyCtrlS:
+		e.save()
+		return true
+	case termbox.KeyCtrlN:
+		e.input_mode = InputName
+		e.input = e.board.name
+		return true
+	case termbox.KeyCtrlE, termbox.KeyCtrlA:
+		e.input_mode = InputEntry
+		if ev.Key == termbox.KeyCtrlA {
+			e.input_mode = InputAirport
+		}
+		e.input = ""
+		if ep := e.board.GetEntryPoint(e.cursor); ep != nil && ep.IsDeclared() {
+			e.input = ep.name
+		}
+		e.in_routes = false
+		return true
+	case termbox.KeyTab:
+		e.in_routes = !e.in_routes
+		return true
+	}
+	e.quit_pending = false
+
+	if e.in_routes {
+		e.route_keys(ev)
+	} else {
+		e.board_keys(ev)
+	}
+	return true
+}
+
+func (e *Editor) input_keys(ev termbox.Event) {
+	switch {
+	case ev.Key == termbox.KeyEsc:
+		e.input_mode = InputNone
+	case ev.Key == termbox.KeyEnter:
+		switch e.input_mode {
+		case InputName:
+			if name := strings.TrimSpace(e.input); name != "" {
+				e.board.name = name
+				e.modified = true
+			}
+		case InputRoute:
+			e.enter_routes()
+		case InputEntry, InputAirport:
+			name := strings.ToUpper(strings.TrimSpace(e.input))
+			if !IsFixName(name) {
+				e.message = "names have at least two letters or digits"
+				break
+			}
+			if ep := e.board.GetEntryPoint(e.cursor); ep != nil && ep.name != name {
+				e.clear_cell(e.cursor)
+			}
+			e.place_entrypoint(name, e.input_mode == InputAirport)
+			e.modified = true
+		}
+		e.input_mode = InputNone
+	case ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2:
+		if e.input != "" {
+			runes := []rune(e.input)
+			e.input = string(runes[:len(runes)-1])
+		}
+	case ev.Key == termbox.KeySpace:
+		e.input += " "
+	case ev.Ch != 0:
+		e.input += string(ev.Ch)
+	}
+}
+
+func (e *Editor) route_keys(ev termbox.Event) {
+	b := e.board
+	switch {
+	case ev.Key == termbox.KeyArrowUp:
+		e.route = Max(0, e.route-1)
+	case ev.Key == termbox.KeyArrowDown:
+		e.route = Max(0, Min(len(b.routes)-1, e.route+1))
+	case ev.Key == termbox.KeyDelete || ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2:
+		if e.route < len(b.routes) {
+			b.routes = append(b.routes[:e.route], b.routes[e.route+1:]...)
+			e.route = Max(0, Min(e.route, len(b.routes)-1))
+			e.modified = true
+		}
+	case ev.Key == termbox.KeyEnter:
+		if e.route < len(b.routes) {
+			e.input_mode = InputRoute
+			e.input = route_line(b.routes[e.route])
+			e.replace = true
+		}
+	case ev.Ch == 'a' || ev.Ch == 'A' || ev.Key == termbox.KeyInsert:
+		e.input_mode = InputRoute
+		e.input = ""
+		e.replace = false
+	case ev.Ch == '+' || ev.Ch == '-':
+		if e.route < len(b.routes) {
+			if ev.Ch == '+' {
+				b.routes[e.route].weight += 1
+			} else {
+				b.routes[e.route].weight = Max(0, b.routes[e.route].weight-1)
+			}
+			e.modified = true
+		}
+	}
+}
+
+func (e *Editor) board_keys(ev termbox.Event) {
+	move := func(d Direction) {
+		if pos := e.cursor.Move(d, 1); e.board.Contains(pos) {
+			e.cursor = pos
+		}
+	}
+
+	switch ev.Key {
+	case termbox.KeyArrowUp:
+		move(DIR_N)
+	case termbox.KeyArrowDown:
+		move(DIR_S)
+	case termbox.KeyArrowLeft:
+		move(DIR_W)
+	case termbox.KeyArrowRight:
+		move(DIR_E)
+	case termbox.KeyDelete, termbox.KeyBackspace, termbox.KeyBackspace2:
+		e.clear_cell(e.cursor)
+		e.modified = true
+	}
+
+	ch := ev.Ch
+	switch {
+	case ch == 0:
+		return
+	case ch >= '0' && ch <= '9':
+		e.place_entrypoint(string(ch), false)
+	case strings.ContainsRune(AIRPORT_SIGNS, ch):
+		e.place_entrypoint(string(ch), true)
+	case ch == '+':
+		e.turn_runway()
+	case ch == '*':
+		e.place_navaid(0)
+	case ch >= 'A' && ch <= 'Z':
+		e.place_navaid(ch)
+	case ch == 'x':
+		e.toggle_nofly()
+	case ch == '.':
+		e.clear_cell(e.cursor)
+	default:
+		return
+	}
+	e.modified = true
+}
+
+// visible board area: left, top and number of columns and rows
+func (e *Editor) layout() (int, int, int, int) {
+	termw, termh := termbox.Size()
+	cols := Min(e.board.width, Max(1, termw-EDITOR_PANEL_WIDTH-4))
+	rows := Min(e.board.height, Max(1, termh-len(EDITOR_HELP)-4))
+
+	// keep the cursor visible
+	e.offset.x = Max(0, Min(e.offset.x, e.cursor.x))
+	e.offset.x = Max(e.offset.x, e.cursor.x-cols+1)
+	e.offset.y = Max(0, Min(e.offset.y, e.cursor.y))
+	e.offset.y = Max(e.offset.y, e.cursor.y-rows+1)
+
+	left := Max(1, (termw-cols-EDITOR_PANEL_WIDTH-2)/2)
+	return left, 2, cols, rows
+}
+
+func (e *Editor) Click(x, y int) {
+	left, top, cols, rows := e.layout()
+	if x >= left && x < left+cols && y >= top && y < top+rows {
+		e.cursor = Position{e.offset.x + x - left, e.offset.y + y - top}
+		e.in_routes = false
+	}
+}
+
+func (e *Editor) Draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	b := e.board
+	left, top, cols, rows := e.layout()
+
+	modified := ""
+	if e.modified {
+		modified = " *"
+	}
+	at := ""
+	if ep := b.GetEntryPoint(e.cursor); ep != nil {
+		at = " " + ep.name
+	}
+	print(left, 0, fmt.Sprintf("Board Editor: %s (%dx%d)%s   cursor %d/%d%s",
+		b.name, b.width, b.height, modified, e.cursor.x, e.cursor.y, at))
+
+	// declared entrypoints by their first letter
+	grid := b.Grid()
+	for _, ep := range b.entrypoints {
+		if ep.IsDeclared() {
+			grid[ep.y][ep.x] = ep.name[0]
+		}
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			ch := grid[e.offset.y+y][e.offset.x+x]
+			color := theme.fix
+			switch ch {
+			case '.':
+				color = theme.board
+			case 'x':
+				color = theme.nofly
+			}
+			if !e.in_routes && (Position{e.offset.x + x, e.offset.y + y}) == e.cursor {
+				color |= termbox.AttrReverse
+			}
+			printC(left+x, top+y, color, string(ch))
+		}
+	}
+
+	// route panel
+	px := left + cols + 3
+	color := termbox.ColorDefault
+	if e.in_routes {
+		color = termbox.AttrBold
+	}
+	printC(px, top-1, color, fmt.Sprintf("Routes (%d)", len(b.routes)))
+
+	list_rows := Max(1, rows-3)
+	first := Max(0, e.route-list_rows+1)
+	for n := first; n < len(b.routes) && n < first+list_rows; n++ {
+		color := termbox.ColorDefault
+		if e.in_routes && n == e.route {
+			color = termbox.AttrReverse
+		}
+		printC(px, top+n-first, color, Pad(EDITOR_PANEL_WIDTH, route_line(b.routes[n]), ""))
+	}
+
+	if err := b.Validate(); err != nil {
+		printC(px, top+rows-1, theme.conflict, err.Error())
+	} else {
+		printC(px, top+rows-1, theme.done, "board is valid")
+	}
+
+	// status and help
+	y := top + rows + 1
+	switch e.input_mode {
+	case InputRoute:
+		x := print(left, y, "route> ", e.input)
+		termbox.SetCursor(x, y)
+	case InputName:
+		x := print(left, y, "name> ", e.input)
+		termbox.SetCursor(x, y)
+	case InputEntry:
+		x := print(left, y, "entrypoint> ", e.input)
+		termbox.SetCursor(x, y)
+	case InputAirport:
+		x := print(left, y, "airport> ", e.input)
+		termbox.SetCursor(x, y)
+	default:
+		print(left, y, e.message)
+		termbox.HideCursor()
+	}
+
+	help := EDITOR_HELP
+	if e.in_routes {
+		help = EDITOR_ROUTE_HELP
+	}
+	for n, line := range help {
+		printC(left, y+2+n, theme.pending, line)
+	}
+}
+
+// edit a board; returns the board as last saved, nil if it was not saved
+func RunEditor(board *Board, filename string) *Board {
+	e := &Editor{board: board.Copy(), filename: filename}
+	defer termbox.HideCursor()
+
+	for {
+		e.Draw()
+		termbox.Flush()
+
+		ev := <-events
+		switch ev.Type {
+		case termbox.EventKey:
+			if !e.KeyPressed(ev) {
+				// edits after the last save are dropped
+				return e.saved
+			}
+		case termbox.EventMouse:
+			if ev.Key == termbox.MouseLeft {
+				e.Click(ev.MouseX, ev.MouseY)
+			}
+		}
+	}
+}
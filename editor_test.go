@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestEditor(t *testing.T) {
+	e := &Editor{board: NewBoard("Test", 16, 12)}
+	b := e.board
+
+	e.cursor = Position{0, 5}
+	e.place_entrypoint("0", false)
+	e.cursor = Position{15, 5}
+	e.place_entrypoint("1", false)
+	e.cursor = Position{8, 0}
+	e.place_entrypoint("%", true)
+	airport := b.entrypoints["%"]
+	if airport == nil || !b.Contains(airport.Move(airport.Direction, 1)) {
+		t.Fatal("runway should point into the board", airport)
+	}
+
+	e.input = route_line(Route{entry: "0", exit: "1", Direction: DIR_E, weight: 2})
+	e.enter_routes()
+	e.input = "1: %-0-" + airport.Direction.String()
+	e.enter_routes()
+	if len(b.routes) != 2 || e.route != 1 || !e.modified {
+		t.Fatal("routes not added", b.routes, e.message)
+	}
+	e.input, e.replace, e.route = "3: 0-1-E", true, 0
+	e.enter_routes()
+	if len(b.routes) != 2 || b.routes[0].weight != 3 {
+		t.Error("route not replaced", b.routes)
+	}
+	e.input = "3: 0-Q-E"
+	e.enter_routes()
+	if len(b.routes) != 2 || e.message == "" {
+		t.Error("invalid route accepted", b.routes)
+	}
+
+	// departures follow the runway
+	dir := airport.Direction
+	e.turn_runway()
+	if airport.Direction != dir.Right(1) || b.routes[1].Direction != airport.Direction {
+		t.Error("runway not turned", airport.Direction, b.routes[1])
+	}
+	e.cursor = Position{5, 5}
+	e.message = ""
+	e.turn_runway()
+	if e.message != "no airport at cursor" {
+		t.Error(e.message)
+	}
+
+	// moving keeps the routes, clearing removes them
+	e.cursor = Position{0, 6}
+	e.place_entrypoint("0", false)
+	if b.entrypoints["0"].Position != e.cursor || len(b.routes) != 2 {
+		t.Error("entrypoint not moved", b.entrypoints["0"], b.routes)
+	}
+	e.cursor = airport.Position
+	e.clear_cell(e.cursor)
+	if _, ok := b.entrypoints["%"]; ok || len(b.routes) != 1 || e.message != "removed routes of %" {
+		t.Error("airport not cleared", b.routes, e.message)
+	}
+
+	// an entrypoint replaces what was in its cell
+	e.cursor = Position{3, 3}
+	e.toggle_nofly()
+	e.place_navaid('A')
+	e.place_entrypoint("2", false)
+	if len(b.nofly) != 0 || len(b.navaids) != 0 || b.GetEntryPoint(e.cursor) == nil {
+		t.Error("cell not cleared", b.nofly, b.navaids)
+	}
+}
+
+// the editor returns the board as saved, not later edits
+func TestEditorSave(t *testing.T) {
+	e := &Editor{board: DEFAULT_BOARD.Copy(), filename: t.TempDir() + "/test.board"}
+	e.save()
+	if e.saved == nil || e.modified {
+		t.Fatal("not saved:", e.message)
+	}
+
+	e.cursor = DEFAULT_BOARD.entrypoints["%"].Position
+	e.clear_cell(e.cursor)
+	if FormatBoard(e.saved) != FormatBoard(DEFAULT_BOARD) || FormatBoard(e.board) == FormatBoard(e.saved) {
+		t.Error("saved board changed by later edits")
+	}
+	if b, err := LoadBoard(e.filename); err != nil || FormatBoard(b) != FormatBoard(e.saved) {
+		t.Error("differs from the file:", err)
+	}
+}
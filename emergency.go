@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+)
+
+type Emergency int
+
+const (
+	EmergencyNone    = Emergency(0)
+	EmergencyFuel    = Emergency(iota)
+	EmergencyMedical = Emergency(iota)
+	EmergencyEngine  = Emergency(iota)
+)
+
+const EMERGENCY_CHANCE = 800       // one in n ticks for each flying plane
+const EMERGENCY_FUEL = 4 * Minutes // fuel left after declaring low fuel
+const EMERGENCY_TIME = 8 * Minutes // time to land after medical or engine emergency
+const ENGINE_FAILURE_MAX_HEIGHT = 2
+
+func (e Emergency) String() string {
+	switch e {
+	case EmergencyNone:
+		return "None"
+	case EmergencyFuel:
+		return "Low fuel"
+	case EmergencyMedical:
+		return "Medical"
+	case EmergencyEngine:
+		return "Engine failure"
+	default:
+		panic("invalid emergency")
+	}
+}
+
+func (p *Plane) DeclareEmergency(e Emergency, airport *EntryPoint) {
+	p.emergency = e
+
+	switch e {
+	case EmergencyFuel:
+		p.fuel_left = Ticks(Min(int(p.fuel_left), int(EMERGENCY_FUEL)))
+	case EmergencyMedical:
+		p.divert(airport)
+	case EmergencyEngine:
+		p.divert(airport)
+		p.want_height = Min(p.want_height, ENGINE_FAILURE_MAX_HEIGHT)
+	}
+}
+
+// land at airport in time; an approach clearance to another airport
+// no longer applies
+func (p *Plane) divert(airport *EntryPoint) {
+	if p.clear_to_aproach != airport.name {
+		p.clear_to_aproach = ""
+	}
+	p.exit = airport
+	p.emergency_left = EMERGENCY_TIME
+}
+
+func (p Plane) HasEmergency() bool {
+	return p.emergency != EmergencyNone && p.IsActive()
+}
+
+func (p Plane) EmergencyMessage() string {
+	switch p.emergency {
+	case EmergencyFuel:
+		return fmt.Sprintf("Mayday %c: Low fuel, %s left",
+			p.callsign, p.fuel_left)
+	case EmergencyMedical, EmergencyEngine:
+		return fmt.Sprintf("Mayday %c: %s, land at %s within %s",
+			p.callsign, p.emergency, p.exit.name, p.emergency_left)
+	default:
+		return ""
+	}
+}
+
+// randomly declare an emergency for one of the flying planes.
+// there is never more than one emergency at the same time.
+func (g *GameState) declareEmergency() {
+	for _, p := range g.planes {
+		if p.HasEmergency() {
+			return
+		}
+	}
+
+	for _, p := range g.planes {
+		if p.state != StateFlying || p.emergency != EmergencyNone {
+			continue
+		}
+		if g.rand.Intn(EMERGENCY_CHANCE) != 0 {
+			continue
+		}
+		g.emergency(p)
+		return
+	}
+}
+
+// declare an emergency for the first flying plane. Returns false if there is none.
+func (g *GameState) forceEmergency() bool {
+	for _, p := range g.planes {
+		if p.state == StateFlying && p.emergency == EmergencyNone {
+			g.emergency(p)
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GameState) emergency(p *Plane) {
+	emergencies := []Emergency{EmergencyFuel}
+
+	airport := g.board.NearestAirport(p.Position)
+	if airport != nil && p.typ.airport_exit && p.height <= 5 {
+		emergencies = append(emergencies, EmergencyMedical, EmergencyEngine)
+	}
+
+	p.DeclareEmergency(emergencies[g.rand.Intn(len(emergencies))], airport)
+	g.RecordPlane(p, p.EmergencyMessage())
+}
+
+func (g *GameState) updateEmergencies() *EndReason {
+	for _, p := range g.planes {
+		if !p.HasEmergency() || p.emergency_left == 0 {
+			continue
+		}
+
+		p.emergency_left -= 1
+		if p.emergency_left == 0 {
+			return &EndReason{
+				message: "Emergency not handled",
+				planes:  []*Plane{p},
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestEmergencySelection(t *testing.T) {
+	found := make(map[Emergency]bool)
+	for seed := int64(0); seed < 50; seed++ {
+		g := testGame()
+		g.rand = rand.New(rand.NewSource(seed))
+		high, low := g.planes[0], g.planes[1]
+		high.height = 7
+		low.height = 3
+
+		// medical and engine emergencies need a low plane that can land
+		if !g.forceEmergency() || high.emergency != EmergencyFuel {
+			t.Fatal(seed, "high plane:", high.emergency)
+		}
+		if !g.forceEmergency() {
+			t.Fatal(seed, "no emergency for the low plane")
+		}
+		found[low.emergency] = true
+		if low.emergency != EmergencyFuel && low.exit != DEFAULT_BOARD.NearestAirport(low.Position) {
+			t.Error(seed, "not diverted:", low.exit.name)
+		}
+		if g.forceEmergency() {
+			t.Error(seed, "all planes already have an emergency")
+		}
+	}
+	for _, e := range []Emergency{EmergencyFuel, EmergencyMedical, EmergencyEngine} {
+		if !found[e] {
+			t.Error("never chosen:", e)
+		}
+	}
+}
+
+func TestEmergencyFailure(t *testing.T) {
+	g := testGame()
+	p := g.planes[0]
+	p.fuel_left = 15 * Minutes
+	p.DeclareEmergency(EmergencyFuel, nil)
+	if p.fuel_left != EMERGENCY_FUEL || p.emergency_left != 0 {
+		t.Error("low fuel:", p.fuel_left, p.emergency_left)
+	}
+
+	// a diverted plane loses its clearance to another airport
+	airport := DEFAULT_BOARD.entrypoints["%"]
+	p = g.planes[1]
+	p.clear_to_aproach = "="
+	p.DeclareEmergency(EmergencyEngine, airport)
+	if p.exit != airport || p.clear_to_aproach != "" || p.want_height != ENGINE_FAILURE_MAX_HEIGHT {
+		t.Error("engine failure:", p.exit.name, p.clear_to_aproach, p.want_height)
+	}
+
+	for n := Ticks(1); n < EMERGENCY_TIME; n++ {
+		if er := g.updateEmergencies(); er != nil {
+			t.Fatal("too early at", n, er.message)
+		}
+	}
+	if er := g.updateEmergencies(); er == nil || er.message != "Emergency not handled" || er.planes[0] != p {
+		t.Error("expected failure:", er)
+	}
+}
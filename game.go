@@ -1,9 +1,14 @@
 package main
 
+import (
+	"math/rand"
+)
+
 type Difficulty struct {
 	name       string
 	duration   Ticks
 	num_planes int
+	profile    *DensityProfile // start times and route mix; nil is flat
 }
 
 type GameRules struct {
@@ -20,6 +25,7 @@ type GameRules struct {
 	have_blackbird bool
 
 	show_pending_planes bool
+	emergencies         bool
 }
 
 var (
@@ -37,6 +43,7 @@ var (
 		have_blackbird: false,
 
 		show_pending_planes: false,
+		emergencies:         false,
 	}
 
 	DEFAULT_RULES = GameRules{
@@ -53,6 +60,7 @@ var (
 		have_blackbird: true,
 
 		show_pending_planes: false,
+		emergencies:         true,
 	}
 
 	RULES = []*GameRules{&DEFAULT_RULES, &ATC_ORIGINAL_RULES}
@@ -61,26 +69,50 @@ var (
 type EndReason struct {
 	message string
 	planes  []*Plane
+	success bool
 }
 
 type GameState struct {
 	rules *GameRules
 	board *Board
 
+	paused bool // clock stopped; time only advances manually
+
 	seed int64
+	rand *rand.Rand // for random events during the game
 
 	clock      Ticks
+	duration   Ticks
 	end_reason *EndReason
+	events     []MissionEvent  // pending; campaign missions and scenarios only
+	event      TranscriptEntry // last mission event fired
+	tutor      *Tutor          // tutorial lessons only
 
 	ci CommandInterpreter
 
+	selected          *Plane
+	strips_by_arrival bool
+	view              View
+
 	planes             []*Plane
 	reusable_callsigns []rune
+
+	transcript       []TranscriptEntry
+	transcript_file  string // set once the transcript is saved
+	transcript_error error  // last failed attempt to save it
+
+	voice Voice
 }
 
 func (g *GameState) Tick() {
 	if g.end_reason == nil {
 		g.end_reason = g.doTick()
+		if g.end_reason != nil {
+			g.Record("", "-- "+g.end_reason.message+" --")
+		}
+		if g.tutor != nil {
+			g.tutor.Update(g)
+		}
 	}
 }
 
@@ -93,6 +125,7 @@ func (g *GameState) doTick() *EndReason {
 	// TODO: update once before first tick
 	remaining := 0
 	for _, p := range g.planes {
+		state := p.state
 		er := p.Tick(g)
 		if er != nil {
 			return er
@@ -106,6 +139,10 @@ func (g *GameState) doTick() *EndReason {
 			g.reusable_callsigns = g.reusable_callsigns[1:]
 		}
 
+		if p.state != state {
+			g.record_state(p)
+		}
+
 		if !p.IsDone() {
 			remaining += 1
 		} else if p.callsign != 0 && len(g.planes) > 26 {
@@ -134,9 +171,17 @@ func (g *GameState) doTick() *EndReason {
 	}
 
 	if remaining == 0 {
-		return &EndReason{message: "Success"}
+		return &EndReason{message: "Success", success: true}
 	}
 
+	if er := g.updateEmergencies(); er != nil {
+		return er
+	}
+	if g.rules.emergencies {
+		g.declareEmergency()
+	}
+	g.runEvents()
+
 	// apply delayed commands
 	g.ci.Tick(g)
 	return nil
@@ -149,6 +194,35 @@ func (g *GameState) KeyPressed(k rune) {
 	g.ci.KeyPressed(g, k)
 }
 
+// click on a board cell: select the plane there or send the
+// selected plane directly to the cell
+func (g *GameState) Click(pos Position) {
+	if g.end_reason != nil {
+		return
+	}
+
+	for _, p := range g.planes {
+		if p.IsFlying() && p.Position == pos && p.callsign != 0 {
+			g.Select(p)
+			return
+		}
+	}
+	g.ci.DirectToCell(g, pos)
+}
+
+// select a plane and preselect its callsign
+func (g *GameState) Select(p *Plane) {
+	g.selected = p
+	g.ci.Select(p)
+}
+
+func (g *GameState) Submit() {
+	if g.end_reason != nil {
+		return
+	}
+	g.ci.Submit(g)
+}
+
 func (g *GameState) FindPlane(callsign rune) *Plane {
 	var plane *Plane
 	for _, p := range g.planes {
@@ -160,6 +234,39 @@ func (g *GameState) FindPlane(callsign rune) *Plane {
 	return plane
 }
 
+// planes holding at the navaid at pos
+func (g *GameState) HoldingStack(pos Position) []*Plane {
+	stack := make([]*Plane, 0)
+	for _, p := range g.planes {
+		if p.IsFlying() && p.is_holding && p.hold_pos == pos {
+			stack = append(stack, p)
+		}
+	}
+	return stack
+}
+
+// another plane holding at the navaid at pos and cleared to the given level
+func (g *GameState) HoldConflict(plane *Plane, pos Position, height int) *Plane {
+	for _, p := range g.HoldingStack(pos) {
+		if p != plane && p.want_height == height {
+			return p
+		}
+	}
+	return nil
+}
+
+// nearest level to the plane's level that is free in the holding stack at pos; 0 if none
+func (g *GameState) FreeHoldLevel(plane *Plane, pos Position) int {
+	for d := 1; d <= plane.MaxHeight(); d++ {
+		for _, level := range []int{plane.want_height + d, plane.want_height - d} {
+			if level >= 1 && level <= plane.MaxHeight() && g.HoldConflict(plane, pos, level) == nil {
+				return level
+			}
+		}
+	}
+	return 0
+}
+
 func (g *GameState) String() string {
 	res := ""
 	for _, p := range g.planes {
@@ -183,11 +290,15 @@ func NewGame(rules *GameRules, board *Board, diff *Difficulty, seed int64) *Game
 
 	var game = &GameState{
 		seed:  seed,
+		rand:  rand.New(rand.NewSource(seed)),
 		rules: rules,
 		board: board,
 
-		clock:  diff.duration,
-		planes: planes,
+		clock:    diff.duration,
+		duration: diff.duration,
+		planes:   planes,
+		voice:    voice,
+		ci:       CommandInterpreter{board: board},
 	}
 	return game
 }
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// parameters of a generated board
+type BoardSpec struct {
+	width, height int
+	entrypoints   int // 2-20, at the edges
+	airports      int // 0-4
+	navaids       int // at least one per airport
+	nofly         int // number of nofly regions
+	seed          int64
+}
+
+var DEFAULT_BOARD_SPEC = BoardSpec{
+	width:       25,
+	height:      20,
+	entrypoints: 8,
+	airports:    2,
+	navaids:     3,
+	nofly:       1,
+}
+
+const (
+	GENERATE_TRIES  = 200
+	MIN_FIX_DIST    = 3 // between entrypoints, airports and navaids
+	MAX_ENTRYPOINTS = 20
+	MAX_AIRPORTS    = 4
+)
+
+// limit the spec to what the board format supports
+func (s BoardSpec) Clamp() BoardSpec {
+	s.width = Min(Max(s.width, 16), 80)
+	s.height = Min(Max(s.height, 12), 60)
+	s.entrypoints = Min(Max(s.entrypoints, 2), MAX_ENTRYPOINTS)
+	s.airports = Min(Max(s.airports, 0), MAX_AIRPORTS)
+	s.navaids = Min(Max(s.navaids, s.airports), 26)
+	s.nofly = Min(Max(s.nofly, 0), 10)
+	return s
+}
+
+type generator struct {
+	r     *rand.Rand
+	b     *Board
+	fixes []Position
+	clear map[Position]bool // cells that must stay free of nofly areas
+	edges map[string]int    // edge of each entrypoint
+}
+
+// generate a random board reproducible from spec.seed. Fails if the
+// fixes do not fit on the board.
+func GenerateBoard(spec BoardSpec) (*Board, error) {
+	spec = spec.Clamp()
+
+	g := generator{
+		r: rand.New(rand.NewSource(spec.seed)),
+		b: &Board{
+			name:        fmt.Sprintf("Generated %d", spec.seed),
+			width:       spec.width,
+			height:      spec.height,
+			entrypoints: make(map[string]*EntryPoint),
+			navaids:     make([]Navaid, 0),
+			routes:      make([]Route, 0),
+			nofly:       make([]Position, 0),
+		},
+		clear: make(map[Position]bool),
+		edges: make(map[string]int),
+	}
+
+	no_room := func(n int, what string) error {
+		return fmt.Errorf("no room for %d %s on a %dx%d board", n, what, spec.width, spec.height)
+	}
+
+	for n := 0; n < spec.airports; n++ {
+		if !g.add_airport(airport_name(n)) {
+			return nil, no_room(spec.airports, "airports")
+		}
+	}
+	// spread the entrypoints over the edges; the next edge if one is full
+	first_edge := g.r.Intn(4)
+	for n := 0; n < spec.entrypoints; n++ {
+		placed := false
+		for k := 0; k < 4 && !placed; k++ {
+			placed = g.add_entrypoint(entrypoint_name(n), (first_edge+n+k)%4)
+		}
+		if !placed {
+			return nil, no_room(spec.entrypoints, "entrypoints")
+		}
+	}
+	for len(g.b.navaids) < spec.navaids {
+		if !g.add_navaid() {
+			return nil, no_room(spec.navaids, "navaids")
+		}
+	}
+	for n := 0; n < spec.nofly; n++ {
+		g.add_nofly()
+	}
+	g.add_routes()
+
+	if err := g.b.Validate(); err != nil {
+		return nil, err
+	}
+	return g.b, nil
+}
+
+// grid signs first, declared names for the rest
+func airport_name(n int) string {
+	if n < len(AIRPORT_SIGNS) {
+		return string(AIRPORT_SIGNS[n])
+	}
+	return fmt.Sprintf("AP%d", n+1)
+}
+
+func entrypoint_name(n int) string {
+	if n < 10 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("EP%d", n)
+}
+
+func (g *generator) is_free(pos Position, dist int) bool {
+	for _, fix := range g.fixes {
+		if fix.Distance(pos) < dist {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *generator) inside(pos Position, margin int) bool {
+	return pos.x >= margin && pos.y >= margin &&
+		pos.x < g.b.width-margin && pos.y < g.b.height-margin
+}
+
+func (g *generator) random_pos(margin int) Position {
+	return Position{
+		RandRange(g.r, margin, g.b.width-1-margin),
+		RandRange(g.r, margin, g.b.height-1-margin),
+	}
+}
+
+// airport with a named navaid on its approach path
+func (g *generator) add_airport(name string) bool {
+	for try := 0; try < GENERATE_TRIES; try++ {
+		pos := g.random_pos(4)
+		dir := DIRECTIONS[g.r.Intn(len(DIRECTIONS))]
+		dist := RandRange(g.r, 3, 6)
+		navaid := pos.Move(dir.Reverse(), dist)
+
+		if !g.inside(navaid, 1) {
+			continue
+		}
+		if !g.is_free(pos, MIN_FIX_DIST+1) || !g.is_free(navaid, MIN_FIX_DIST) {
+			continue
+		}
+
+		g.b.entrypoints[name] = &EntryPoint{
+			name:       name,
+			Position:   pos,
+			Direction:  dir,
+			is_airport: true,
+		}
+		g.b.navaids = append(g.b.navaids, Navaid{name: rune('A' + len(g.b.navaids)), Position: navaid})
+		g.fixes = append(g.fixes, pos, pos.Move(dir, 1), navaid)
+
+		// approach and departure paths
+		for k := -4; k <= dist; k++ {
+			g.clear[pos.Move(dir.Reverse(), k)] = true
+		}
+		return true
+	}
+	return false
+}
+
+// entrypoint at an edge: 0 top, 1 right, 2 bottom, 3 left
+func (g *generator) add_entrypoint(name string, edge int) bool {
+	for try := 0; try < GENERATE_TRIES; try++ {
+		var pos Position
+		switch edge {
+		case 0: // top
+			pos = Position{RandRange(g.r, 2, g.b.width-3), 0}
+		case 1: // right
+			pos = Position{g.b.width - 1, RandRange(g.r, 2, g.b.height-3)}
+		case 2: // bottom
+			pos = Position{RandRange(g.r, 2, g.b.width-3), g.b.height - 1}
+		case 3: // left
+			pos = Position{0, RandRange(g.r, 2, g.b.height-3)}
+		}
+		if !g.is_free(pos, MIN_FIX_DIST) {
+			continue
+		}
+
+		g.b.entrypoints[name] = &EntryPoint{name: name, Position: pos}
+		g.edges[name] = edge
+		g.fixes = append(g.fixes, pos)
+		return true
+	}
+	return false
+}
+
+func (g *generator) add_navaid() bool {
+	for try := 0; try < GENERATE_TRIES; try++ {
+		pos := g.random_pos(3)
+		if !g.is_free(pos, MIN_FIX_DIST+1) {
+			continue
+		}
+		g.b.navaids = append(g.b.navaids, Navaid{name: rune('A' + len(g.b.navaids)), Position: pos})
+		g.fixes = append(g.fixes, pos)
+		return true
+	}
+	return false
+}
+
+// rectangular nofly area away from fixes and approach paths
+func (g *generator) add_nofly() {
+retry:
+	for try := 0; try < GENERATE_TRIES; try++ {
+		w, h := RandRange(g.r, 2, 5), RandRange(g.r, 2, 4)
+		corner := g.random_pos(2)
+
+		cells := make([]Position, 0, w*h)
+		for x := corner.x; x < corner.x+w; x++ {
+			for y := corner.y; y < corner.y+h; y++ {
+				pos := Position{x, y}
+				if !g.inside(pos, 2) || g.clear[pos] || !g.is_free(pos, MIN_FIX_DIST) {
+					continue retry
+				}
+				cells = append(cells, pos)
+			}
+		}
+		g.b.nofly = append(g.b.nofly, cells...)
+		return
+	}
+}
+
+// direction into the board from an edge
+func edge_direction(edge int) Direction {
+	return []Direction{DIR_S, DIR_W, DIR_N, DIR_E}[edge]
+}
+
+// direction from an entrypoint towards pos within 45 degrees of the edge direction
+func (g *generator) entry_direction(name string, pos Position) Direction {
+	ep := g.b.entrypoints[name]
+	inward := edge_direction(g.edges[name])
+
+	dir, _, _ := ep.Position.Direction(pos)
+	if Abs(inward.TurnTo(dir)) <= 1 && g.b.Contains(ep.Move(dir, 1)) {
+		return dir
+	}
+	return inward
+}
+
+// routes between the edges and to and from the airports
+func (g *generator) add_routes() {
+	var edges, airports []string
+	for n := 0; n < MAX_ENTRYPOINTS; n++ {
+		if _, ok := g.b.entrypoints[entrypoint_name(n)]; ok {
+			edges = append(edges, entrypoint_name(n))
+		}
+	}
+	for n := 0; n < MAX_AIRPORTS; n++ {
+		if _, ok := g.b.entrypoints[airport_name(n)]; ok {
+			airports = append(airports, airport_name(n))
+		}
+	}
+
+	for _, entry := range edges {
+		for _, exit := range edges {
+			diff := Abs(g.edges[entry] - g.edges[exit])
+			if entry == exit || diff == 0 {
+				continue
+			}
+
+			weight := 2
+			if diff == 2 {
+				// opposite edges
+				weight = 4
+			}
+			g.add_route(entry, exit, g.entry_direction(entry, g.b.entrypoints[exit].Position), weight)
+		}
+
+		for _, airport := range airports {
+			ap := g.b.entrypoints[airport]
+			g.add_route(entry, airport, g.entry_direction(entry, ap.Position), 1)
+			g.add_route(airport, entry, ap.Direction, 1)
+		}
+	}
+
+	for _, entry := range airports {
+		for _, exit := range airports {
+			g.add_route(entry, exit, g.b.entrypoints[entry].Direction, 2)
+		}
+	}
+}
+
+func (g *generator) add_route(entry, exit string, dir Direction, weight int) {
+	g.b.routes = append(g.b.routes, Route{
+		entry:     entry,
+		exit:      exit,
+		Direction: dir,
+		weight:    weight,
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 )
 
 const (
@@ -35,6 +36,15 @@ func (d Direction) Reverse() Direction {
 	return d.Right(4)
 }
 
+// number of steps to turn right (negative: left) to reach d2
+func (d Direction) TurnTo(d2 Direction) int {
+	n := (int(d2) - int(d) + DIR_MAX) % DIR_MAX
+	if n > DIR_MAX/2 {
+		n -= DIR_MAX
+	}
+	return n
+}
+
 func (d Direction) String() string {
 	switch d {
 	case DIR_N:
@@ -118,3 +128,18 @@ func (p Position) Distance(p2 Position) int {
 	dx, dy := p2.x-p.x, p2.y-p.y
 	return Max(Abs(dx), Abs(dy))
 }
+
+// nearest direction towards p2, the distance and whether p2 lies exactly in that direction
+func (p Position) Direction(p2 Position) (Direction, int, bool) {
+	dx, dy := p2.x-p.x, p2.y-p.y
+	if dx == 0 && dy == 0 {
+		return DIR_N, 0, false
+	}
+
+	// angle clockwise from north; y grows to the south
+	angle := math.Atan2(float64(dx), float64(-dy))
+	d := DIR_N.Right(int(math.Floor(angle/(math.Pi/4) + 0.5)))
+
+	exact := dx == 0 || dy == 0 || Abs(dx) == Abs(dy)
+	return d, p.Distance(p2), exact
+}
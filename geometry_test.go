@@ -45,3 +45,44 @@ func TestPosDirection(t *testing.T) {
 		}
 	}
 }
+
+func TestNearestDirection(t *testing.T) {
+	p := Position{5, 5}
+	for _, c := range []struct {
+		p2    Position
+		d     Direction
+		exact bool
+	}{
+		{Position{24, 10}, DIR_E, false},
+		{Position{8, 0}, DIR_NE, false},
+		{Position{5, 20}, DIR_S, true},
+		{Position{0, 10}, DIR_SW, true},
+		{Position{4, 0}, DIR_N, false},
+	} {
+		d, dist, exact := p.Direction(c.p2)
+		if d != c.d || exact != c.exact || dist != p.Distance(c.p2) {
+			t.Error(c.p2, d, dist, exact)
+		}
+	}
+	if _, dist, exact := p.Direction(p); dist != 0 || exact {
+		t.Error("same position")
+	}
+}
+
+func TestTurnTo(t *testing.T) {
+	test := func(d, d2 Direction, expected int) {
+		if res := d.TurnTo(d2); res != expected {
+			t.Error(d, d2, expected, "!=", res)
+		}
+		if d.Right(d.TurnTo(d2)) != d2 {
+			t.Error(d, d2, "not reached")
+		}
+	}
+	test(DIR_N, DIR_N, 0)
+	test(DIR_N, DIR_E, 2)
+	test(DIR_N, DIR_W, -2)
+	test(DIR_NW, DIR_NE, 2)
+	test(DIR_E, DIR_W, 4)
+	test(DIR_SE, DIR_NW, 4)
+	test(DIR_S, DIR_NE, -3)
+}
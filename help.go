@@ -1,26 +1,41 @@
 package main
 
-var HELP []string = []string{
-	`
-      Commands:
-        <aircraft>A0     aproach airport
-        <aircraft>A<1-5> assign altitude
-        <aircraft>M      maintain current altitude
-        <aircraft>L<0-4> turn left
-        <aircraft>R<0-4> turn right
-        <aircraft>P      proceed on current heading
-        <aircraft>H      hold at navaid
-        <aircraft>K      keep current position
-        <aircraft><airport>
-                         turn towards airport at navaid
-
-        <aircraft>S      status of aircraft
-
-        Esc              quit game
-        ,                advance time
-        ?                show help
-        Tab              show planes`,
-	`
+import (
+	"fmt"
+	"strings"
+)
+
+type CommandHelp struct {
+	command rune   // replaces %c in syntax with its key; 0: none
+	syntax  string // after <aircraft>
+	help    string // empty: same as next entry
+}
+
+var COMMAND_HELP = []CommandHelp{
+	{'A', "%c0", "aproach airport"},
+	{'A', "%c<1-5>", "assign altitude"},
+	{'M', "%c", "maintain current altitude"},
+	{'L', "%c<0-4>", "turn left"},
+	{'R', "%c<0-4>", "turn right"},
+	{'P', "%c", "proceed on current heading"},
+	{'V', "%c<1-3>", "speed slow/normal/fast"},
+	{'D', "%c<fix>", "fly direct to entrypoint, airport or navaid"},
+	{'H', "%c", "hold at navaid"},
+	{'W', "%c<L|R><0-9>", "hold left/right with leg length"},
+	{'K', "%c", "keep current position"},
+	{0, "% =", ""},
+	{'T', "%c<airport>", "turn towards airport at navaid"},
+	{'H', "@<navaid>%c", ""},
+	{'W', "@<navaid>%c<L|R><0-9>", ""},
+	{'T', "@<navaid>%c<airport>", "hold or turn at the named navaid"},
+	{0, "^<level><command>", "command when reaching level"},
+	{0, "@<fix><command>", "command when over fix (*: any navaid)"},
+	{'C', "%c", "cancel conditional commands"},
+	{'S', "%c", "status of aircraft"},
+	{0, string(REPEAT_KEY), "repeat last command for aircraft (or any)"},
+}
+
+var HELP_PLANES = `
       Airplanes:
         Jet (Mark: M)
           A jet is a very common plane that usually
@@ -41,5 +56,51 @@ var HELP []string = []string{
 
         Blackbird (Mark: B)
           A very rare, fast and high flying plane.
-          Usually you should not mess with it.`,
+          Usually you should not mess with it.`
+
+func help_line(keys string, help string) string {
+	if help == "" {
+		return "        " + keys
+	}
+	if len(keys) > 16 {
+		return fmt.Sprintf("        %s\n%25s%s", keys, "", help)
+	}
+	return fmt.Sprintf("        %-17s%s", keys, help)
+}
+
+// help pages for the active key bindings
+func HelpPages() []string {
+	var commands strings.Builder
+	commands.WriteString("\n      Commands:\n")
+	for _, h := range COMMAND_HELP {
+		syntax := h.syntax
+		if h.command != 0 {
+			syntax = fmt.Sprintf(syntax, key_bindings.CommandKey(h.command))
+		}
+		commands.WriteString(help_line("<aircraft>"+syntax, h.help) + "\n")
+	}
+	commands.WriteString("\n")
+	commands.WriteString(help_line(
+		CHAIN_PREFIX+"<aircraft>:<command><command>[;<aircraft>:...]",
+		"several commands at once ("+key_bindings.ActionKey(ActionSubmit)+")"))
+
+	var keys strings.Builder
+	keys.WriteString("\n      Keys:\n")
+	for _, binding := range key_bindings.actions {
+		names := make([]string, len(binding.keys))
+		for n, key := range binding.keys {
+			names[n] = key.String()
+		}
+		keys.WriteString(help_line(strings.Join(names, " / "), binding.help) + "\n")
+	}
+	keys.WriteString(help_line("Up / Down", "recall previous commands") + "\n")
+	keys.WriteString(help_line("Left / Right", "edit command") + "\n")
+	keys.WriteString("\n")
+	keys.WriteString("        Mouse: click a plane to select it, then\n")
+	keys.WriteString("        click a cell to send it there.\n")
+	if filename := KeyBindingsFile(); filename != "" {
+		keys.WriteString("\n        Key bindings: " + filename)
+	}
+
+	return []string{commands.String(), keys.String(), HELP_PLANES}
 }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	termbox "github.com/nsf/termbox-go"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// characters with a fixed meaning in the command syntax
+	COMMAND_SYNTAX = ".@^+\":;%=*0123456789"
+
+	// commands with a letter that can be remapped
+	REMAPPABLE_COMMANDS = "SMPHKCLRAVDWT"
+)
+
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionQuit
+	ActionAdvance
+	ActionPause
+	ActionHelp
+	ActionPlanes
+	ActionDelayed
+	ActionHistory
+	ActionTranscript
+	ActionClear
+	ActionSubmit
+	ActionSelectPrev
+	ActionSelectNext
+	ActionSortStrips
+	ActionTracks
+	ActionScrollLeft
+	ActionScrollRight
+	ActionScrollUp
+	ActionScrollDown
+	ActionZoom
+	ActionFollow
+)
+
+// a key or a character
+type Key struct {
+	key termbox.Key
+	ch  rune
+}
+
+var KEY_NAMES = map[string]termbox.Key{
+	"Esc":       termbox.KeyEsc,
+	"Enter":     termbox.KeyEnter,
+	"Space":     termbox.KeySpace,
+	"Tab":       termbox.KeyTab,
+	"Backspace": termbox.KeyBackspace2,
+	"Delete":    termbox.KeyDelete,
+	"Insert":    termbox.KeyInsert,
+	"Home":      termbox.KeyHome,
+	"End":       termbox.KeyEnd,
+	"PgUp":      termbox.KeyPgup,
+	"PgDn":      termbox.KeyPgdn,
+	"Up":        termbox.KeyArrowUp,
+	"Down":      termbox.KeyArrowDown,
+	"Left":      termbox.KeyArrowLeft,
+	"Right":     termbox.KeyArrowRight,
+	"F1":        termbox.KeyF1,
+	"F2":        termbox.KeyF2,
+	"F3":        termbox.KeyF3,
+	"F4":        termbox.KeyF4,
+	"F5":        termbox.KeyF5,
+	"F6":        termbox.KeyF6,
+	"F7":        termbox.KeyF7,
+	"F8":        termbox.KeyF8,
+	"F9":        termbox.KeyF9,
+	"F10":       termbox.KeyF10,
+	"F11":       termbox.KeyF11,
+	"F12":       termbox.KeyF12,
+}
+
+// keys used for editing the prompt; not available for actions. Ctrl+H is
+// the other backspace code.
+var RESERVED_KEYS = []string{
+	"Backspace", "Ctrl+H", "Delete", "Home", "End", "Up", "Down", "Left", "Right", "Ctrl+C",
+}
+
+// parse a key name ("Tab", "Ctrl+R", "F5") or a single character
+func ParseKey(s string) (Key, error) {
+	if key, ok := KEY_NAMES[s]; ok {
+		return Key{key: key}, nil
+	}
+	if strings.HasPrefix(s, "Ctrl+") && len(s) == 6 && s[5] >= 'A' && s[5] <= 'Z' {
+		return Key{key: termbox.KeyCtrlA + termbox.Key(s[5]-'A')}, nil
+	}
+	if utf8.RuneCountInString(s) == 1 {
+		ch, _ := utf8.DecodeRuneInString(s)
+		return Key{ch: ch}, nil
+	}
+	return Key{}, fmt.Errorf("unknown key %q", s)
+}
+
+func (k Key) String() string {
+	if k.ch != 0 {
+		return string(k.ch)
+	}
+	for name, key := range KEY_NAMES {
+		if key == k.key {
+			return name
+		}
+	}
+	if k.key >= termbox.KeyCtrlA && k.key <= termbox.KeyCtrlZ {
+		return fmt.Sprintf("Ctrl+%c", 'A'+rune(k.key-termbox.KeyCtrlA))
+	}
+	return "?"
+}
+
+func (k Key) Matches(ev termbox.Event) bool {
+	if k.ch != 0 {
+		return ev.Ch == k.ch
+	}
+	if ev.Ch != 0 {
+		return false
+	}
+	switch k.key {
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		return ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2
+	}
+	return ev.Key == k.key
+}
+
+type KeyBinding struct {
+	action Action
+	name   string // name in the config file
+	help   string
+	keys   []Key
+}
+
+type KeyBindings struct {
+	actions  []KeyBinding
+	commands map[rune]rune // key -> command
+}
+
+var DEFAULT_KEY_BINDINGS = KeyBindings{
+	actions: []KeyBinding{
+		{ActionQuit, "quit", "quit game", []Key{{key: termbox.KeyEsc}}},
+		{ActionAdvance, "advance", "advance time", []Key{{ch: ','}}},
+		{ActionPause, "pause", "pause / resume clock", []Key{{key: termbox.KeyCtrlP}}},
+		{ActionHelp, "help", "show help", []Key{{ch: '?'}}},
+		{ActionPlanes, "planes", "show planes", []Key{{key: termbox.KeyTab}}},
+		{ActionDelayed, "delayed", "show delayed commands", []Key{{ch: '#'}}},
+		{ActionHistory, "history", "show command history", []Key{{key: termbox.KeyPgup}}},
+		{ActionTranscript, "transcript", "show radio transcript", []Key{{key: termbox.KeyCtrlR}}},
+		{ActionClear, "clear", "clear command", []Key{{key: termbox.KeySpace}}},
+		{ActionSubmit, "submit", "submit command", []Key{{key: termbox.KeyEnter}}},
+		{ActionSelectPrev, "select-prev", "select previous flight strip", []Key{{ch: '['}}},
+		{ActionSelectNext, "select-next", "select next flight strip", []Key{{ch: ']'}}},
+		{ActionSortStrips, "sort-strips", "sort strips by arrival", []Key{{ch: '|'}}},
+		{ActionTracks, "tracks", "show / hide predicted tracks", []Key{{key: termbox.KeyCtrlT}}},
+		{ActionScrollLeft, "scroll-left", "scroll radar left", []Key{{ch: '<'}}},
+		{ActionScrollRight, "scroll-right", "scroll radar right", []Key{{ch: '>'}}},
+		{ActionScrollUp, "scroll-up", "scroll radar up", []Key{{ch: '{'}}},
+		{ActionScrollDown, "scroll-down", "scroll radar down", []Key{{ch: '}'}}},
+		{ActionZoom, "zoom", "compact / normal radar", []Key{{ch: '~'}}},
+		{ActionFollow, "follow", "follow selected plane", []Key{{key: termbox.KeyCtrlF}}},
+	},
+	commands: map[rune]rune{},
+}
+
+// active bindings
+var key_bindings = &DEFAULT_KEY_BINDINGS
+
+// names of the remappable commands in the config file
+var COMMAND_NAMES = map[string]rune{
+	"status":    'S',
+	"maintain":  'M',
+	"proceed":   'P',
+	"hold":      'H',
+	"keep":      'K',
+	"cancel":    'C',
+	"left":      'L',
+	"right":     'R',
+	"altitude":  'A',
+	"speed":     'V',
+	"direct":    'D',
+	"hold-turn": 'W',
+	"approach":  'T',
+}
+
+// name of a remappable command in the config file
+func CommandName(command rune) string {
+	for name, c := range COMMAND_NAMES {
+		if c == command {
+			return name
+		}
+	}
+	return string(command)
+}
+
+func (kb *KeyBindings) Action(ev termbox.Event) Action {
+	for _, binding := range kb.actions {
+		for _, key := range binding.keys {
+			if key.Matches(ev) {
+				return binding.action
+			}
+		}
+	}
+	return ActionNone
+}
+
+// first key of an action for display
+func (kb *KeyBindings) ActionKey(action Action) string {
+	for _, binding := range kb.actions {
+		if binding.action == action && len(binding.keys) > 0 {
+			return binding.keys[0].String()
+		}
+	}
+	return ""
+}
+
+// command for a typed key
+func (kb *KeyBindings) Command(key rune) rune {
+	if command, ok := kb.commands[key]; ok {
+		return command
+	}
+	if kb.CommandKey(key) != key {
+		// letter was moved to another key
+		return 0
+	}
+	return key
+}
+
+// key for a command
+func (kb *KeyBindings) CommandKey(command rune) rune {
+	for key, c := range kb.commands {
+		if c == command {
+			return key
+		}
+	}
+	return command
+}
+
+// check for keys bound twice and keys needed elsewhere
+func (kb *KeyBindings) Validate() error {
+	used := make(map[Key]string)
+
+	for _, name := range RESERVED_KEYS {
+		key, _ := ParseKey(name)
+		used[key] = "editing"
+	}
+
+	for _, binding := range kb.actions {
+		for _, key := range binding.keys {
+			if key.ch >= 'A' && key.ch <= 'Z' || key.ch >= 'a' && key.ch <= 'z' {
+				return fmt.Errorf("%s: letter %c is used for aircraft", binding.name, key.ch)
+			}
+			if key.ch != 0 && strings.ContainsRune(COMMAND_SYNTAX, key.ch) {
+				return fmt.Errorf("%s: %c is used in commands", binding.name, key.ch)
+			}
+			if other, ok := used[key]; ok {
+				return fmt.Errorf("%s: key %s already used for %s", binding.name, key, other)
+			}
+			used[key] = binding.name
+		}
+	}
+
+	for _, command := range REMAPPABLE_COMMANDS {
+		key := kb.CommandKey(command)
+		if key < 'A' || key > 'Z' {
+			return fmt.Errorf("command %c: key must be a letter", command)
+		}
+		for _, other := range REMAPPABLE_COMMANDS {
+			if other != command && kb.CommandKey(other) == key {
+				return fmt.Errorf("%s = %c: %c is reserved for %s, map %s to another key first",
+					CommandName(command), key, key, CommandName(other), CommandName(other))
+			}
+		}
+	}
+	return nil
+}
+
+func KeyBindingsFile() string {
+	return ConfigFile("keys.conf")
+}
+
+// read key bindings: lines "<action or command> = <key> [<key>...]".
+// Actions not mentioned keep their default keys.
+func ReadKeyBindings(filename string) (*KeyBindings, error) {
+	kb := &KeyBindings{commands: make(map[rune]rune)}
+	kb.actions = append(kb.actions, DEFAULT_KEY_BINDINGS.actions...)
+
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return kb, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line_nr := 1; scanner.Scan(); line_nr += 1 {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected <action> = <keys>", filename, line_nr)
+		}
+		name := strings.TrimSpace(parts[0])
+		names := strings.Fields(parts[1])
+
+		if command, ok := COMMAND_NAMES[name]; ok {
+			if len(names) != 1 {
+				return nil, fmt.Errorf("%s:%d: one key expected for %s", filename, line_nr, name)
+			}
+			key := []rune(strings.ToUpper(names[0]))
+			if len(key) != 1 {
+				return nil, fmt.Errorf("%s:%d: letter expected for %s", filename, line_nr, name)
+			}
+			if _, ok := kb.commands[key[0]]; ok {
+				return nil, fmt.Errorf("%s:%d: key %c already used", filename, line_nr, key[0])
+			}
+			kb.commands[key[0]] = command
+			continue
+		}
+
+		n := kb.find(name)
+		if n < 0 {
+			return nil, fmt.Errorf("%s:%d: unknown action %q", filename, line_nr, name)
+		}
+		kb.actions[n].keys = nil
+		for _, s := range names {
+			key, err := ParseKey(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", filename, line_nr, err)
+			}
+			kb.actions[n].keys = append(kb.actions[n].keys, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for key, command := range kb.commands {
+		if key == command {
+			delete(kb.commands, key)
+		}
+	}
+
+	if err := kb.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return kb, nil
+}
+
+func (kb *KeyBindings) find(name string) int {
+	for n, binding := range kb.actions {
+		if binding.name == name {
+			return n
+		}
+	}
+	return -1
+}
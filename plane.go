@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"sort"
@@ -20,6 +21,23 @@ const (
 	StateDeparted = iota
 )
 
+type Speed int
+
+const (
+	SpeedSlow   = Speed(-1)
+	SpeedNormal = Speed(0)
+	SpeedFast   = Speed(1)
+)
+
+// holding pattern: half circle, leg, half circle, leg
+type Hold struct {
+	right   bool
+	leg     int   // straight moves between the turns
+	release Ticks // expected release time; planes leave the hold by command only
+}
+
+const HOLD_RELEASE_TIME = 2 * Minutes // per plane in the holding stack
+
 const SAFE_DISTANCE = 3
 const FUEL_INDICATOR = 10 * Minutes
 const REUSE_ENTRYPOINT_TIME = 3 * Minutes
@@ -42,6 +60,7 @@ type Plane struct {
 
 	Direction
 	want_turn int
+	speed     Speed
 
 	height         int
 	want_height    int
@@ -50,11 +69,24 @@ type Plane struct {
 
 	hold_at_navaid   bool
 	is_holding       bool
-	clear_to_aproach rune
+	clear_to_aproach string
+	at_navaid        rune // hold or turn only at this navaid; 0: next navaid
+	direct_to        *Fix
+
+	hold      Hold
+	hold_pos  Position
+	hold_step int
+
+	conditions []*Command
+
+	fuel_steps int // steps of DoTick that burnt fuel
+
+	emergency      Emergency
+	emergency_left Ticks
 }
 
 func (p *Plane) Tick(game *GameState) (er *EndReason) {
-	for n := 1; n <= p.typ.moves_per_tick; n += 1 {
+	for n := 1; n <= p.Cadence().moves_per_tick; n += 1 {
 		er := p.DoTick(game)
 		if er != nil {
 			return er
@@ -65,8 +97,9 @@ func (p *Plane) Tick(game *GameState) (er *EndReason) {
 
 func (p *Plane) DoTick(game *GameState) *EndReason {
 	if p.IsConsumingFuel() {
-		p.fuel_left -= 1
-		if p.fuel_left == 0 {
+		p.fuel_steps += 1
+		p.fuel_left -= p.FuelBurn()
+		if p.fuel_left <= 0 {
 			return &EndReason{
 				message: "Fuel exhausted",
 				planes:  []*Plane{p},
@@ -110,8 +143,12 @@ func (p *Plane) DoTick(game *GameState) *EndReason {
 
 		p.state = StateFlying
 	case StateFlying, StateAproach:
+		if p.direct_to != nil {
+			p.SteerTowards(p.direct_to.Position)
+		}
+
 		if p.is_holding {
-			p.Direction = p.Direction.Left(1)
+			p.HoldStep()
 		}
 
 		er := p.UpdatePosition(game)
@@ -120,19 +157,30 @@ func (p *Plane) DoTick(game *GameState) *EndReason {
 		}
 		p.ApplyWants()
 
+		if p.direct_to != nil && p.ReachedFix() {
+			// fix reached; continue on current heading
+			p.direct_to = nil
+			p.want_turn = 0
+		}
+
+		if p.state == StateFlying {
+			p.ApplyConditions(game)
+		}
+
 		navaid := game.board.GetNavaid(p.Position)
-		if navaid != nil {
-			if p.hold_at_navaid {
-				p.is_holding = true
+		if navaid != nil && (p.at_navaid == 0 || p.at_navaid == navaid.name) {
+			if p.hold_at_navaid && !p.is_holding {
+				p.EnterHold(game, navaid.Position)
 			}
 
 			if ep, ok := game.board.entrypoints[p.clear_to_aproach]; ok {
 				// always use the direction of the airport.
 				p.Direction = ep.Direction
+				p.is_holding = false
 			}
 		}
 
-		p.wait_ticks = p.typ.ticks_per_move - 1
+		p.wait_ticks = p.Cadence().ticks_per_move - 1
 	case StateDeparted, StateLanded:
 	default:
 		panic("unhandled case")
@@ -140,6 +188,30 @@ func (p *Plane) DoTick(game *GameState) *EndReason {
 	return nil
 }
 
+func (p Plane) Cadence() Cadence {
+	switch p.speed {
+	case SpeedSlow:
+		return p.typ.slow_speed
+	case SpeedFast:
+		return p.typ.fast_speed
+	default:
+		return Cadence{p.typ.ticks_per_move, p.typ.moves_per_tick}
+	}
+}
+
+// fuel burnt in the current step of DoTick, which runs moves_per_tick
+// times a tick. Fast planes burn 50% more, slow planes 25% less.
+func (p Plane) FuelBurn() Ticks {
+	switch {
+	case p.speed == SpeedFast && p.fuel_steps%2 == 0:
+		return 2
+	case p.speed == SpeedSlow && p.fuel_steps%4 == 0:
+		return 0
+	default:
+		return 1
+	}
+}
+
 func (p *Plane) Collides(p2 *Plane) bool {
 	height_match := false
 
@@ -177,6 +249,90 @@ func (p *Plane) ApplyWants() {
 	}
 }
 
+// apply conditional commands whose condition is met
+func (p *Plane) ApplyConditions(game *GameState) {
+	conditions := p.conditions
+	p.conditions = nil
+
+	for _, c := range conditions {
+		if c.cond.Met(game.board, p) {
+			cmd := *c
+			cmd.cond = nil
+			reply, ok := cmd.Apply(game, p)
+			game.RecordPlane(p, c.Instruction()+": "+reply)
+			if ok && cmd.command == 'C' {
+				// the conditions not yet checked are cancelled as well
+				return
+			}
+		} else {
+			p.conditions = append(p.conditions, c)
+		}
+	}
+}
+
+// join the holding stack at pos. A plane on an occupied level moves to the
+// nearest free one; if the stack is full it does not hold.
+func (p *Plane) EnterHold(game *GameState, pos Position) {
+	if other := game.HoldConflict(p, pos, p.want_height); other != nil {
+		level := game.FreeHoldLevel(p, pos)
+		if level == 0 {
+			p.hold_at_navaid = false
+			game.RecordPlane(p, fmt.Sprintf("holding stack full, level %d occupied by %c", p.want_height, other.callsign))
+			return
+		}
+		game.RecordPlane(p, fmt.Sprintf("level %d occupied by %c, holding at level %d", p.want_height, other.callsign, level))
+		p.want_height = level
+	}
+
+	// the stack is released from the bottom
+	below := 0
+	for _, other := range game.HoldingStack(pos) {
+		if other.want_height < p.want_height {
+			below += 1
+		} else {
+			other.hold.release = Ticks(Max(0, int(other.hold.release-HOLD_RELEASE_TIME)))
+		}
+	}
+
+	p.is_holding = true
+	p.hold_pos = pos
+	p.hold_step = 0
+	p.hold.release = Ticks(Max(0, int(game.clock-HOLD_RELEASE_TIME*Ticks(below+1))))
+}
+
+func (p *Plane) HoldStep() {
+	if p.hold_step%(4+p.hold.leg) < 4 {
+		if p.hold.right {
+			p.Direction = p.Direction.Right(1)
+		} else {
+			p.Direction = p.Direction.Left(1)
+		}
+	}
+	p.hold_step += 1
+}
+
+// turn towards pos. Planes without immediate_turn turn one step per move.
+func (p *Plane) SteerTowards(pos Position) {
+	dir, _, _ := p.Position.Direction(pos)
+	if p.typ.immediate_turn {
+		p.Direction = dir
+		p.want_turn = 0
+	} else {
+		p.want_turn = p.Direction.TurnTo(dir)
+	}
+}
+
+// over the direct-to fix, or next to it without heading there. Turning
+// takes a move, so a plane that cannot turn at once would circle the fix.
+func (p Plane) ReachedFix() bool {
+	fix := p.direct_to.Position
+	if p.Position == fix {
+		return true
+	}
+	dir, dist, _ := p.Position.Direction(fix)
+	return !p.typ.immediate_turn && dist <= 1 && dir != p.Direction
+}
+
 func (p *Plane) UpdatePosition(game *GameState) *EndReason {
 	next_pos := p.Position
 	if !p.is_hoovering {
@@ -227,9 +383,9 @@ func (p *Plane) UpdatePosition(game *GameState) *EndReason {
 	return nil
 }
 
-func (p *Plane) DoTurn(c int) bool {
+func (p *Plane) DoTurn(c int) error {
 	if c < -4 || c > 4 {
-		return false
+		return errors.New("cannot turn more than 4")
 	}
 
 	if p.typ.immediate_turn {
@@ -240,23 +396,28 @@ func (p *Plane) DoTurn(c int) bool {
 	}
 	p.is_holding = false
 	p.hold_at_navaid = false
-	p.clear_to_aproach = 0
-	return true
+	p.clear_to_aproach = ""
+	p.at_navaid = 0
+	p.direct_to = nil
+	return nil
 }
 
-func (p *Plane) DoHeight(h int) bool {
-	if h > 5 || h < 0 {
-		return false
+func (p *Plane) DoHeight(h int) error {
+	if h > p.MaxHeight() && p.emergency == EmergencyEngine {
+		return fmt.Errorf("engine failure, maximum level %d", p.MaxHeight())
+	}
+	if h > p.MaxHeight() || h < 0 {
+		return fmt.Errorf("maximum level %d", p.MaxHeight())
 	}
 
 	if h == 0 {
 		// aproach
 		if p.state != StateFlying {
-			return false
+			return errors.New("not airborne")
 		}
 		p.state = StateAproach
 		p.want_height = h
-		return true
+		return nil
 	}
 
 	p.want_height = h
@@ -265,27 +426,64 @@ func (p *Plane) DoHeight(h int) bool {
 		p.state = StateRolling
 		p.wait_ticks = p.typ.ticks_rolling
 	}
-	return true
+	return nil
+}
+
+func (p *Plane) DoSpeed(s Speed) error {
+	switch s {
+	case SpeedSlow:
+		if !p.typ.slow_speed.Valid() {
+			return fmt.Errorf("%s cannot fly slower", p.typ.Name())
+		}
+	case SpeedFast:
+		if !p.typ.fast_speed.Valid() {
+			return fmt.Errorf("%s cannot fly faster", p.typ.Name())
+		}
+	}
+	p.speed = s
+	return nil
+}
+
+func (p *Plane) DoDirectTo(fix *Fix) error {
+	if fix.Position == p.Position {
+		return fmt.Errorf("already over %s", fix)
+	}
+	p.direct_to = fix
+	p.is_holding = false
+	p.hold_at_navaid = false
+	p.clear_to_aproach = ""
+	p.at_navaid = 0
+	return nil
 }
 
-func (p *Plane) DoHold() bool {
+func (p *Plane) DoHold(navaid rune, right bool, leg int) error {
 	p.hold_at_navaid = true
-	p.clear_to_aproach = 0
-	return true
+	p.hold = Hold{right: right, leg: leg}
+	p.clear_to_aproach = ""
+	p.at_navaid = navaid
+	return nil
 }
 
-func (p *Plane) DoKeep() bool {
+func (p *Plane) DoKeep() error {
 	if !p.typ.can_hoover {
-		return false
+		return fmt.Errorf("%s cannot hover", p.typ.Name())
 	}
 	p.is_hoovering = !p.is_hoovering
-	return true
+	return nil
 }
 
-func (p *Plane) TurnAtNavaid(navaid rune) bool {
-	p.clear_to_aproach = navaid
+func (p *Plane) TurnAtNavaid(airport string, navaid rune) error {
+	p.clear_to_aproach = airport
 	p.hold_at_navaid = false
-	return true
+	p.at_navaid = navaid
+	return nil
+}
+
+func (p Plane) MaxHeight() int {
+	if p.emergency == EmergencyEngine {
+		return ENGINE_FAILURE_MAX_HEIGHT
+	}
+	return 5
 }
 
 func (p Plane) AcceptsCommands() bool {
@@ -323,11 +521,11 @@ func (p Plane) String() string {
 
 func (p Plane) Flightplan() string {
 	if p.initial_height <= 9 {
-		return fmt.Sprintf("%c%d%c %c-%c",
-			p.callsign, p.initial_height, p.typ.mark, p.entry.sign, p.exit.sign)
+		return fmt.Sprintf("%c%d%c %s-%s",
+			p.callsign, p.initial_height, p.typ.mark, p.entry.name, p.exit.name)
 	} else {
-		return fmt.Sprintf("%cX%c %c-%c",
-			p.callsign, p.typ.mark, p.entry.sign, p.exit.sign)
+		return fmt.Sprintf("%cX%c %s-%s",
+			p.callsign, p.typ.mark, p.entry.name, p.exit.name)
 	}
 }
 
@@ -340,13 +538,20 @@ func (p Plane) Marker() string {
 }
 
 func (p Plane) State() string {
-	res := fmt.Sprintf("%s%c %c-%c %-2s",
-		p.Marker(), p.typ.mark, p.entry.sign, p.exit.sign, p.Direction)
+	res := fmt.Sprintf("%s%c %s-%s %-2s",
+		p.Marker(), p.typ.mark, p.entry.name, p.exit.name, p.Direction)
 
 	if p.is_hoovering {
 		res += " H "
 	}
 
+	switch p.speed {
+	case SpeedSlow:
+		res += " < "
+	case SpeedFast:
+		res += " > "
+	}
+
 	if p.fuel_left >= FUEL_INDICATOR {
 		res += " + "
 	}
@@ -365,6 +570,10 @@ func (p Plane) State() string {
 		res += fmt.Sprintf(" [%s]",
 			p.Direction.Right(p.want_turn))
 	}
+
+	for _, c := range p.conditions {
+		res += " {" + c.Instruction() + "}"
+	}
 	return res
 }
 
@@ -376,25 +585,47 @@ func (p Plane) StateMessage() string {
 	case p.state == StateRolling:
 		res += " -- Rolling! --"
 	case p.is_holding:
-		res += " -- Holding --"
+		res += " -- " + p.HoldMessage() + " --"
 	case p.state == StateAproach:
 		res += " -- Final Approach --"
-	case p.clear_to_aproach != 0:
+	case p.clear_to_aproach != "" && p.at_navaid != 0:
+		res += " -- Cleared at " + string(p.at_navaid) + " --"
+	case p.clear_to_aproach != "":
 		res += " -- Cleared --"
+	case p.direct_to != nil:
+		res += " -- Direct " + p.direct_to.String() + " --"
 	case p.state == StateLanded:
 		res += " -- Landed --"
 	case p.state == StateDeparted:
 		res += " -- Departed Area --"
 	}
 
+	if p.HasEmergency() {
+		res += " !! " + p.emergency.String() + " !!"
+	}
+
 	return res
 }
 
+func (p Plane) HoldMessage() string {
+	res := "Holding"
+	if p.hold.right {
+		res += fmt.Sprintf(" R%d", p.hold.leg)
+	} else {
+		res += fmt.Sprintf(" L%d", p.hold.leg)
+	}
+	if p.at_navaid != 0 {
+		res += " at " + string(p.at_navaid)
+	}
+	return res + ", release " + p.hold.release.String()
+}
+
 func MakePlanes(rules *GameRules, board *Board, diff *Difficulty, seed int64) []*Plane {
 	planes := make([]*Plane, 0, diff.num_planes)
 
 	r := rand.New(rand.NewSource(seed))
 	plane_types := PlaneTypes(rules)
+	profile := diff.Profile()
 
 	for n := 0; n < diff.num_planes; n++ {
 		var plane *Plane
@@ -408,56 +639,25 @@ func MakePlanes(rules *GameRules, board *Board, diff *Difficulty, seed int64) []
 			tries++
 
 			typ := ChoosePlaneType(r, plane_types)
-			route := ChooseRoute(r, board.routes)
+			phase := profile.choose(r)
+			route := ChooseRoute(r, profile.phases[phase].routes(board))
 
 			// entries are present. checked in board.go
 			entry := board.entrypoints[route.entry]
 			exit := board.entrypoints[route.exit]
 
-			if !typ.entry_exit_routes && !entry.is_airport && !exit.is_airport {
+			if typ.RouteError(entry, exit) != "" {
 				continue retry_plane
 			}
 
-			if !typ.airport_loop && entry == exit && entry.is_airport {
-				continue retry_plane
-			}
-
-			if !typ.airport_entry && entry.is_airport {
-				continue retry_plane
-			}
-
-			if !typ.airport_exit && exit.is_airport {
-				continue retry_plane
-			}
-
-			start := Ticks(RandRange(r, int(rules.last_plane_start), int(diff.duration)))
+			start := profile.start(r, phase, diff.duration, rules.last_plane_start)
 
 			height := RandRange(r, typ.entry_min_height, typ.entry_max_height)
 			if entry.is_airport {
 				height = 0
 			}
 
-			plane = &Plane{
-				typ: typ,
-
-				entry: entry,
-				exit:  exit,
-
-				Position:  entry.Position,
-				Direction: route.Direction,
-
-				start:     start,
-				fuel_left: typ.initial_fuel,
-
-				height:         height,
-				want_height:    height,
-				initial_height: height,
-
-				is_holding:   false,
-				is_hoovering: typ.can_hoover && entry.is_airport,
-
-				hold_at_navaid: exit.is_airport,
-			}
+			plane = NewPlane(typ, entry, exit, route.Direction, start, height)
 
 			// no two planes from the same origin share the same altitude<
 			for _, other_plane := range planes {
@@ -489,6 +689,31 @@ func MakePlanes(rules *GameRules, board *Board, diff *Difficulty, seed int64) []
 	return planes
 }
 
+// plane from entry to exit; visible once the clock reaches start
+func NewPlane(typ *PlaneType, entry, exit *EntryPoint, dir Direction, start Ticks, height int) *Plane {
+	return &Plane{
+		typ: typ,
+
+		entry: entry,
+		exit:  exit,
+
+		Position:  entry.Position,
+		Direction: dir,
+
+		start:     start,
+		fuel_left: typ.initial_fuel,
+
+		height:         height,
+		want_height:    height,
+		initial_height: height,
+
+		is_holding:   false,
+		is_hoovering: typ.can_hoover && entry.is_airport,
+
+		hold_at_navaid: exit.is_airport,
+	}
+}
+
 type ByTime []*Plane
 
 func (a ByTime) Len() int           { return len(a) }
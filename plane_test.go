@@ -0,0 +1,161 @@
+package main
+
+import "testing"
+
+// direct-to overrides holds and approach clearances
+func TestSteerToFix(t *testing.T) {
+	g := testGame()
+	p := g.planes[0]
+	p.Position = Position{10, 10}
+	p.fuel_left = 15 * Minutes
+	p.exit = DEFAULT_BOARD.entrypoints["%"]
+	p.hold_at_navaid = true
+	p.TurnAtNavaid("%", 0)
+
+	fix := DEFAULT_BOARD.FindFix("9")
+	if err := p.DoDirectTo(fix); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 30 && p.direct_to != nil; n++ {
+		if er := p.Tick(g); er != nil {
+			t.Fatal(er.message)
+		}
+		if p.is_holding || p.Direction != DIR_E {
+			t.Fatal("left the direct track at", p.Position, p.Direction)
+		}
+	}
+	if p.Position != fix.Position {
+		t.Error("fix not reached", p.Position)
+	}
+
+	// a jet turns one step per move towards the fix
+	p.Position, p.Direction = Position{5, 5}, DIR_N
+	p.DoDirectTo(fix)
+	p.Tick(g)
+	if p.Direction != DIR_NE || p.want_turn != 1 {
+		t.Error("expected a gradual turn", p.Direction, p.want_turn)
+	}
+
+	// a fix next to the path must not make a jet circle it
+	p.Position, p.Direction = Position{10, 4}, DIR_E
+	p.DoDirectTo(&Fix{Position: Position{11, 5}})
+	for n := 0; n < 3 && p.direct_to != nil; n++ {
+		p.Tick(g)
+	}
+	if p.direct_to != nil {
+		t.Error("circling the fix at", p.Position, p.Direction)
+	}
+
+	// nor any other fix nearby, whatever the heading
+	center := Position{12, 14}
+	for _, d := range DIRECTIONS {
+		for dx := -3; dx <= 3; dx++ {
+			for dy := -3; dy <= 3; dy++ {
+				fix := &Fix{Position: Position{center.x + dx, center.y + dy}}
+				p.Position, p.Direction, p.want_turn = center, d, 0
+				p.fuel_left = 15 * Minutes
+				if p.DoDirectTo(fix) != nil {
+					continue
+				}
+				for n := 0; n < 12 && p.direct_to != nil; n++ {
+					if er := p.Tick(g); er != nil {
+						t.Fatal(er.message)
+					}
+				}
+				if p.direct_to != nil {
+					t.Error("fix", dx, dy, "not reached heading", d)
+				}
+			}
+		}
+	}
+}
+
+// half circle, leg, half circle, leg: back at the start after a full pattern
+func TestHoldStep(t *testing.T) {
+	for _, leg := range []int{0, 1, 3} {
+		p := &Plane{Direction: DIR_E, hold: Hold{right: true, leg: leg}}
+		start := p.Position
+		for n := 0; n < 2*(4+leg); n++ {
+			p.HoldStep()
+			p.Position = p.Position.Move(p.Direction, 1)
+			if n == 3 && p.Direction != DIR_W {
+				t.Error(leg, "half circle", p.Direction)
+			}
+			if n == 4+leg-1 && leg > 0 && p.Direction != DIR_W {
+				t.Error(leg, "leg", p.Direction)
+			}
+		}
+		if p.Direction != DIR_E || p.Position != start {
+			t.Error(leg, "pattern not closed", p.Position, p.Direction)
+		}
+	}
+}
+
+func TestHoldingStack(t *testing.T) {
+	g := testGame()
+	navaid := Position{9, 10}
+	b, c := g.planes[0], g.planes[1]
+	b.EnterHold(g, navaid)
+	if !b.is_holding || b.want_height != 5 {
+		t.Fatal("B not holding", b.HoldMessage())
+	}
+
+	// C joins on the next free level
+	c.EnterHold(g, navaid)
+	if !c.is_holding || c.want_height != 4 {
+		t.Error("C not re-levelled", c.want_height)
+	}
+	if c.hold.release <= b.hold.release {
+		t.Error("C below B is released first", c.hold.release, b.hold.release)
+	}
+
+	// the stack is full
+	p := *b
+	p.callsign, p.is_holding = 'D', false
+	g.planes = append(g.planes, &p)
+	for level := 1; level <= 3; level++ {
+		other := *c
+		other.want_height = level
+		g.planes = append(g.planes, &other)
+	}
+	p.hold_at_navaid = true
+	p.EnterHold(g, navaid)
+	if p.is_holding || p.hold_at_navaid {
+		t.Error("D joined a full stack", p.want_height)
+	}
+}
+
+// moves and fuel over 12 ticks of a prop at each speed
+func TestSpeed(t *testing.T) {
+	for _, tc := range []struct {
+		speed Speed
+		moves int
+		fuel  Ticks
+	}{
+		{SpeedNormal, 6, 12},
+		{SpeedSlow, 4, 9},
+		{SpeedFast, 12, 18},
+	} {
+		g := testGame()
+		p := g.planes[0]
+		p.typ = &PLANE_TYPE_PROP
+		p.Position = Position{3, 3}
+		p.fuel_left = 15 * Minutes
+		if err := p.DoSpeed(tc.speed); err != nil {
+			t.Fatal(err)
+		}
+
+		// the clock stands still; the burn follows the plane's steps
+		for n := 0; n < 12; n++ {
+			if er := p.Tick(g); er != nil {
+				t.Fatal(er.message)
+			}
+		}
+		if moves := p.x - 3; moves != tc.moves {
+			t.Error(tc.speed, "moves", moves)
+		}
+		if fuel := 15*Minutes - p.fuel_left; fuel != tc.fuel {
+			t.Error(tc.speed, "fuel", fuel)
+		}
+	}
+}
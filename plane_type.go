@@ -1,5 +1,18 @@
 package main
 
+import (
+	"strings"
+)
+
+type Cadence struct {
+	ticks_per_move Ticks
+	moves_per_tick int
+}
+
+func (c Cadence) Valid() bool {
+	return c.ticks_per_move > 0 && c.moves_per_tick > 0
+}
+
 type PlaneType struct {
 	mark   rune
 	name   string
@@ -10,6 +23,10 @@ type PlaneType struct {
 	ticks_pending  Ticks
 	ticks_rolling  Ticks
 
+	// cadence for speed commands; zero if not available
+	slow_speed Cadence
+	fast_speed Cadence
+
 	entry_min_height int
 	entry_max_height int
 	exit_height      int
@@ -37,6 +54,8 @@ var (
 		ticks_pending:  4,
 		ticks_rolling:  2,
 
+		slow_speed: Cadence{ticks_per_move: 2, moves_per_tick: 1},
+
 		entry_min_height: 6,
 		entry_max_height: 9,
 		exit_height:      5,
@@ -62,6 +81,9 @@ var (
 		ticks_pending:  4,
 		ticks_rolling:  4,
 
+		slow_speed: Cadence{ticks_per_move: 3, moves_per_tick: 1},
+		fast_speed: Cadence{ticks_per_move: 1, moves_per_tick: 1},
+
 		entry_min_height: 6,
 		entry_max_height: 9,
 		exit_height:      5,
@@ -87,6 +109,8 @@ var (
 		ticks_pending:  4,
 		ticks_rolling:  0,
 
+		slow_speed: Cadence{ticks_per_move: 4, moves_per_tick: 1},
+
 		entry_min_height: 2,
 		entry_max_height: 4,
 		exit_height:      5,
@@ -112,6 +136,8 @@ var (
 		ticks_pending:  2,
 		ticks_rolling:  0,
 
+		slow_speed: Cadence{ticks_per_move: 1, moves_per_tick: 1},
+
 		entry_min_height: 10,
 		entry_max_height: 10,
 		exit_height:      10,
@@ -129,6 +155,29 @@ var (
 	}
 )
 
+// name used in pilot messages
+func (pt PlaneType) Name() string {
+	if pt.can_hoover {
+		return "helicopter"
+	}
+	return strings.ToLower(pt.name)
+}
+
+// why the type cannot fly from entry to exit; "" if it can
+func (pt PlaneType) RouteError(entry, exit *EntryPoint) string {
+	switch {
+	case !pt.entry_exit_routes && !entry.is_airport && !exit.is_airport:
+		return "does not fly between entrypoints"
+	case !pt.airport_loop && entry == exit && entry.is_airport:
+		return "does not return to its airport"
+	case !pt.airport_entry && entry.is_airport:
+		return "does not take off at airports"
+	case !pt.airport_exit && exit.is_airport:
+		return "does not land at airports"
+	}
+	return ""
+}
+
 func PlaneTypes(rules *GameRules) []*PlaneType {
 	plane_types := make([]*PlaneType, 0, 3)
 	if rules.have_jet {
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+)
+
+const HISTORY_SIZE = 50
+
+type HistoryEntry struct {
+	command string
+	reply   string
+}
+
+func (ci *CommandInterpreter) add_history(command string, reply string) {
+	ci.history = append(ci.history, HistoryEntry{command: command, reply: reply})
+	if len(ci.history) > HISTORY_SIZE {
+		ci.history = ci.history[len(ci.history)-HISTORY_SIZE:]
+	}
+	ci.history_pos = 0
+}
+
+func (ci *CommandInterpreter) set_buffer(s string) {
+	ci.buf = s
+	ci.cursor = len([]rune(s))
+}
+
+func (ci *CommandInterpreter) insert(key rune) {
+	r := []rune(ci.buf)
+	cursor := Max(0, Min(ci.cursor, len(r)))
+
+	r = append(r[:cursor], append([]rune{key}, r[cursor:]...)...)
+	ci.buf = string(r)
+	ci.cursor = cursor + 1
+}
+
+// delete character before the cursor. Clears the status line if the buffer is empty.
+func (ci *CommandInterpreter) Backspace() {
+	if ci.buf == "" {
+		ci.Clear()
+		return
+	}
+	if ci.cursor > 0 {
+		r := []rune(ci.buf)
+		ci.buf = string(append(r[:ci.cursor-1], r[ci.cursor:]...))
+		ci.cursor -= 1
+	}
+}
+
+// delete character under the cursor
+func (ci *CommandInterpreter) Delete() {
+	r := []rune(ci.buf)
+	if ci.cursor < len(r) {
+		ci.buf = string(append(r[:ci.cursor], r[ci.cursor+1:]...))
+	}
+}
+
+func (ci *CommandInterpreter) MoveCursor(n int) {
+	ci.cursor = Max(0, Min(ci.cursor+n, len([]rune(ci.buf))))
+}
+
+func (ci *CommandInterpreter) CursorHome() {
+	ci.cursor = 0
+}
+
+func (ci *CommandInterpreter) CursorEnd() {
+	ci.cursor = len([]rune(ci.buf))
+}
+
+// put an earlier (n > 0) or later (n < 0) command from the history into the buffer
+func (ci *CommandInterpreter) Recall(n int) {
+	if len(ci.history) == 0 {
+		return
+	}
+
+	ci.history_pos = Min(ci.history_pos+n, len(ci.history))
+	if ci.history_pos <= 0 {
+		ci.history_pos = 0
+		ci.set_buffer("")
+		return
+	}
+	ci.set_buffer(ci.history[len(ci.history)-ci.history_pos].command)
+}
+
+// last commands with replies, newest first
+func (ci CommandInterpreter) Scrollback(n int) []HistoryEntry {
+	res := make([]HistoryEntry, 0, n)
+	for i := len(ci.history) - 1; i >= 0 && len(res) < n; i-- {
+		res = append(res, ci.history[i])
+	}
+	return res
+}
+
+// preselect the callsign of a plane
+func (ci *CommandInterpreter) Select(p *Plane) {
+	if p.callsign != 0 {
+		ci.set_buffer(string(p.callsign))
+	}
+}
+
+// send the aircraft in the buffer directly to pos
+func (ci *CommandInterpreter) DirectToCell(g *GameState, pos Position) {
+	delayed := len(ci.buf) - len(strings.TrimLeft(ci.buf, "."))
+	callsign := ci.buf[delayed:]
+	if len(callsign) != 1 || callsign[0] < 'A' || callsign[0] > 'Z' {
+		return
+	}
+
+	cmd := &Command{
+		valid:    true,
+		delayed:  delayed,
+		callsign: rune(callsign[0]),
+		command:  'D',
+	}
+	if fix := g.board.FixAt(pos); fix != nil {
+		cmd.fix = fix.name
+	} else {
+		cmd.target = &Fix{Position: pos}
+	}
+	ci.set_buffer(cmd.String())
+	ci.run(g, cmd)
+}
+
+// replace <aircraft>" in the buffer by the last command for that aircraft,
+// or by the last command for any aircraft if it had none yet
+func (ci *CommandInterpreter) repeat_last() {
+	prefix := strings.TrimSuffix(ci.buf, string(REPEAT_KEY))
+	delayed := len(prefix) - len(strings.TrimLeft(prefix, "."))
+	callsign := prefix[delayed:]
+	if len(callsign) != 1 || callsign[0] < 'A' || callsign[0] > 'Z' {
+		return
+	}
+
+	var last *Command
+	for i := len(ci.history) - 1; i >= 0; i-- {
+		cmd, used := ci.parse_command(ci.history[i].command)
+		if cmd == nil || !cmd.valid || used != len(ci.history[i].command) {
+			continue
+		}
+		if last == nil {
+			last = cmd
+		}
+		if cmd.callsign == rune(callsign[0]) {
+			last = cmd
+			break
+		}
+	}
+	if last != nil {
+		last.callsign = rune(callsign[0])
+		last.delayed = delayed
+		ci.set_buffer(last.String())
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+)
+
+// pilot readback after the command has been applied to the plane
+func (c Command) Readback(p *Plane) string {
+	if c.cond != nil {
+		inner := c
+		inner.cond = nil
+		return fmt.Sprintf("wilco %s, %s", c.cond.Describe(), inner.Intent())
+	}
+
+	heading := p.Direction.Right(p.want_turn)
+
+	switch c.command {
+	case 'L', 'R':
+		switch {
+		case c.arg == 0:
+			return fmt.Sprintf("maintaining heading %s", heading)
+		case p.typ.immediate_turn:
+			return fmt.Sprintf("turned on the spot, heading %s", heading)
+		case c.command == 'L':
+			return fmt.Sprintf("turning left heading %s", heading)
+		default:
+			return fmt.Sprintf("turning right heading %s", heading)
+		}
+	case 'A', 'M':
+		switch {
+		case p.state == StateAproach:
+			return fmt.Sprintf("cleared to land at %s, descending", p.exit.name)
+		case p.state == StateRolling:
+			return fmt.Sprintf("cleared for takeoff, climbing level %d", p.want_height)
+		case p.want_height > p.height:
+			return fmt.Sprintf("climbing level %d", p.want_height)
+		case p.want_height < p.height:
+			return fmt.Sprintf("descending level %d", p.want_height)
+		default:
+			return fmt.Sprintf("maintaining level %d", p.want_height)
+		}
+	case 'P':
+		return fmt.Sprintf("proceeding heading %s", heading)
+	case 'K':
+		if p.is_hoovering {
+			return "hovering"
+		}
+		return fmt.Sprintf("resuming flight heading %s", p.Direction)
+	default:
+		return c.Intent()
+	}
+}
+
+// what the plane is going to do; independent of the plane state
+func (c Command) Intent() string {
+	where := "at next navaid"
+	if c.at != 0 {
+		where = "at " + string(c.at)
+	}
+
+	switch c.command {
+	case 'L':
+		return fmt.Sprintf("turn left %d", c.arg)
+	case 'R':
+		return fmt.Sprintf("turn right %d", c.arg)
+	case 'A':
+		if c.arg == 0 {
+			return "approach"
+		}
+		return fmt.Sprintf("level %d", c.arg)
+	case 'M':
+		return "maintain level"
+	case 'P':
+		return "proceed on heading"
+	case 'V':
+		switch c.arg {
+		case 1:
+			return "reducing speed"
+		case 3:
+			return "increasing speed"
+		default:
+			return "normal speed"
+		}
+	case 'D':
+		if c.target != nil {
+			return "direct " + c.target.String()
+		}
+		return "direct " + c.fix
+	case 'H':
+		return "holding " + where
+	case 'W':
+		side := "left"
+		if c.right {
+			side = "right"
+		}
+		return fmt.Sprintf("holding %s, leg %d %s", side, c.arg, where)
+	case 'C':
+		return "conditional commands cancelled"
+	case 'K':
+		return "hover"
+	case 'T':
+		return fmt.Sprintf("turning towards %s %s", c.fix, where)
+	case 'S':
+		return "report status"
+	default:
+		return c.Instruction()
+	}
+}
+
+func (c Condition) Describe() string {
+	switch {
+	case c.fix == "*":
+		return "at next navaid"
+	case c.fix != "":
+		return "over " + c.fix
+	default:
+		return fmt.Sprintf("reaching level %d", c.level)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hand-placed traffic on a board
+type Scenario struct {
+	name     string
+	board    *Board
+	rules    *GameRules
+	duration Ticks
+	planes   []ScenarioPlane
+	events   []MissionEvent
+}
+
+type ScenarioPlane struct {
+	callsign rune
+	typ      *PlaneType
+	route    Route
+	start    Ticks // game time since the start
+	height   int
+	fuel     Ticks
+}
+
+var PLANE_TYPES = []*PlaneType{
+	&PLANE_TYPE_JET, &PLANE_TYPE_PROP, &PLANE_TYPE_HELI, &PLANE_TYPE_BLACKBIRD,
+}
+
+// plane type by name or mark
+func FindPlaneType(name string) *PlaneType {
+	for _, typ := range PLANE_TYPES {
+		if strings.EqualFold(typ.name, name) || strings.EqualFold(string(typ.mark), name) {
+			return typ
+		}
+	}
+	return nil
+}
+
+func FindRules(name string) *GameRules {
+	for _, r := range RULES {
+		if strings.EqualFold(r.name, name) {
+			return r
+		}
+	}
+	return nil
+}
+
+// board by name: built-in, in the boards directory or a board file
+func FindBoard(name string, dir string) (*Board, error) {
+	for _, b := range append(append([]*Board{}, BOARDS...), LoadBoardFiles()...) {
+		if strings.EqualFold(b.name, name) {
+			return b, nil
+		}
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	return LoadBoard(name)
+}
+
+// game time "<minutes>" or "<minutes>:<seconds>"
+func parse_time(s string) Ticks {
+	parts := strings.SplitN(s, ":", 2)
+	minutes, err := strconv.Atoi(parts[0])
+	if err != nil || minutes < 0 {
+		panic("invalid time: " + s)
+	}
+	seconds := 0
+	if len(parts) == 2 {
+		seconds, err = strconv.Atoi(parts[1])
+		if err != nil || seconds < 0 || seconds >= 60 {
+			panic("invalid time: " + s)
+		}
+	}
+	return Ticks(minutes)*Minutes + Ticks(seconds/SECONDS_PER_TICK)
+}
+
+// parse a plane "plane <callsign> <type> <entry>-<exit>[-<direction>] <start> [<height> [<fuel>]]"
+func (sc *Scenario) parse_plane(fields []string) ScenarioPlane {
+	if len(fields) < 5 || len(fields) > 7 {
+		panic("expected plane <callsign> <type> <entry>-<exit> <start> [<height> [<fuel>]]")
+	}
+
+	var sp ScenarioPlane
+	callsign := []rune(fields[1])
+	if len(callsign) != 1 || callsign[0] < 'A' || callsign[0] > 'Z' {
+		panic("invalid callsign: " + fields[1])
+	}
+	sp.callsign = callsign[0]
+
+	if sp.typ = FindPlaneType(fields[2]); sp.typ == nil {
+		panic("unknown plane type: " + fields[2])
+	}
+	allowed := false
+	for _, typ := range PlaneTypes(sc.rules) {
+		allowed = allowed || typ == sp.typ
+	}
+	if !allowed {
+		panic(fmt.Sprintf("no %s with rules %s", sp.typ.Name(), sc.rules.name))
+	}
+
+	route := strings.Split(fields[3], "-")
+	if len(route) != 2 && len(route) != 3 {
+		panic("expected <entry>-<exit>[-<direction>]: " + fields[3])
+	}
+	entry, ok_entry := sc.board.entrypoints[route[0]]
+	exit, ok_exit := sc.board.entrypoints[route[1]]
+	if !ok_entry || !ok_exit {
+		panic("unknown entrypoint: " + route[0] + " or " + route[1])
+	}
+	if err := sp.typ.RouteError(entry, exit); err != "" {
+		panic(fmt.Sprintf("%s %s %s", sp.typ.Name(), err, fields[3]))
+	}
+	sp.route = Route{entry: route[0], exit: route[1]}
+
+	switch {
+	case len(route) == 3:
+		sp.route.Direction = ParseDirection(route[2])
+		if entry.is_airport && sp.route.Direction != entry.Direction {
+			panic(fmt.Sprintf("runway of %s points %s", entry.name, entry.Direction))
+		}
+		if !sc.board.Contains(entry.Move(sp.route.Direction, 1)) {
+			panic(fmt.Sprintf("direction %s leaves the board at %s", sp.route.Direction, entry.name))
+		}
+	case entry.is_airport:
+		sp.route.Direction = entry.Direction
+	default:
+		// direction of the board route
+		found := false
+		for _, r := range sc.board.routes {
+			if r.entry == sp.route.entry && r.exit == sp.route.exit {
+				sp.route.Direction, found = r.Direction, true
+				break
+			}
+		}
+		if !found {
+			panic("no route " + fields[3] + ", give a direction")
+		}
+	}
+
+	sp.start = parse_time(fields[4])
+
+	sp.height = sp.typ.entry_min_height
+	if len(fields) > 5 {
+		height, err := strconv.Atoi(fields[5])
+		if err != nil || height < 1 || height > sp.typ.entry_max_height {
+			panic(fmt.Sprintf("invalid height: %s, %s enters at 1-%d", fields[5], sp.typ.Name(), sp.typ.entry_max_height))
+		}
+		sp.height = height
+	}
+	if entry.is_airport {
+		sp.height = 0
+	}
+
+	sp.fuel = sp.typ.initial_fuel
+	if len(fields) > 6 {
+		sp.fuel = parse_time(fields[6])
+	}
+	return sp
+}
+
+// parse an event "event <time> [emergency] <message>"
+func parse_event(fields []string) MissionEvent {
+	if len(fields) < 3 {
+		panic("expected event <time> [emergency] <message>")
+	}
+	event := MissionEvent{after: parse_time(fields[1])}
+	if fields[2] == "emergency" {
+		event.emergency = true
+		fields = fields[1:]
+	}
+	event.message = strings.Join(fields[2:], " ")
+	if event.message == "" {
+		panic("event without message")
+	}
+	return event
+}
+
+// parse a scenario file:
+//
+//	name: <name>
+//	board: <board name or board file>
+//	rules: <rules name>
+//	duration: <time>
+//	plane <callsign> <type> <entry>-<exit>[-<direction>] <start> [<height> [<fuel>]]
+//	event <time> [emergency] <message>
+//
+// Times are <minutes> or <minutes>:<seconds> since the start of the game.
+// The board and rules come before the planes; plane types and routes must
+// be allowed by them. The direction defaults to the board route. Board files
+// are relative to dir. Lines starting with # are comments.
+func ReadScenario(s string, dir string) (sc *Scenario, err error) {
+	line_nr := 0
+	defer func() {
+		if r := recover(); r != nil {
+			sc, err = nil, fmt.Errorf("invalid scenario: line %d: %v", line_nr, r)
+		}
+	}()
+
+	sc = &Scenario{
+		name:     "Unnamed",
+		board:    DEFAULT_BOARD,
+		rules:    &DEFAULT_RULES,
+		duration: 30 * Minutes,
+	}
+
+	callsigns := make(map[rune]bool)
+	for _, l := range strings.Split(s, "\n") {
+		line_nr += 1
+		l = strings.TrimSpace(l)
+		fields := strings.Fields(l)
+		value := func(key string) string {
+			return strings.TrimSpace(strings.TrimPrefix(l, key))
+		}
+
+		switch {
+		case l == "" || strings.HasPrefix(l, "#"):
+		case strings.HasPrefix(l, "name:"):
+			sc.name = value("name:")
+		case strings.HasPrefix(l, "board:"):
+			if len(sc.planes) > 0 {
+				panic("board after the planes")
+			}
+			b, err := FindBoard(value("board:"), dir)
+			if err != nil {
+				panic(err)
+			}
+			sc.board = b
+		case strings.HasPrefix(l, "rules:"):
+			if len(sc.planes) > 0 {
+				panic("rules after the planes")
+			}
+			if sc.rules = FindRules(value("rules:")); sc.rules == nil {
+				panic("unknown rules: " + value("rules:"))
+			}
+		case strings.HasPrefix(l, "duration:"):
+			sc.duration = parse_time(value("duration:"))
+		case fields[0] == "plane":
+			sp := sc.parse_plane(fields)
+			if callsigns[sp.callsign] {
+				panic("duplicate callsign: " + string(sp.callsign))
+			}
+			callsigns[sp.callsign] = true
+			sc.planes = append(sc.planes, sp)
+		case fields[0] == "event":
+			sc.events = append(sc.events, parse_event(fields))
+		default:
+			panic("unknown line: " + l)
+		}
+	}
+
+	line_nr = 0
+	if len(sc.planes) == 0 {
+		return nil, fmt.Errorf("invalid scenario: no planes")
+	}
+	for _, sp := range sc.planes {
+		if sp.start >= sc.duration {
+			return nil, fmt.Errorf("invalid scenario: plane %c starts after the end", sp.callsign)
+		}
+	}
+	return sc, nil
+}
+
+func LoadScenario(filename string) (*Scenario, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := ReadScenario(string(data), filepath.Dir(filename))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return sc, nil
+}
+
+func ScenariosDir() string {
+	return ConfigFile("scenarios")
+}
+
+// scenarios in the scenarios directory; unreadable files are skipped
+func LoadScenarioFiles() []*Scenario {
+	scenarios := make([]*Scenario, 0)
+	dir := ScenariosDir()
+	if dir == "" {
+		return scenarios
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.scenario"))
+	sort.Strings(files)
+	for _, filename := range files {
+		if sc, err := LoadScenario(filename); err == nil {
+			scenarios = append(scenarios, sc)
+		}
+	}
+	return scenarios
+}
+
+func (sc *Scenario) NewGame() *GameState {
+	diff := &Difficulty{name: sc.name, duration: sc.duration}
+	game := NewGame(sc.rules, sc.board, diff, 0)
+
+	for _, sp := range sc.planes {
+		entry := sc.board.entrypoints[sp.route.entry]
+		exit := sc.board.entrypoints[sp.route.exit]
+		p := NewPlane(sp.typ, entry, exit, sp.route.Direction, sc.duration-sp.start, sp.height)
+		p.callsign = sp.callsign
+		p.fuel_left = sp.fuel
+		game.planes = append(game.planes, p)
+	}
+	sort.Sort(ByTime(game.planes))
+
+	game.events = append([]MissionEvent{}, sc.events...)
+	return game
+}
+
+func ScenarioMenu() {
+	scenarios := LoadScenarioFiles()
+	title := "Scenarios"
+	if len(scenarios) == 0 {
+		title = "No scenarios in " + ScenariosDir()
+	}
+
+	menu := make([]string, 0, len(scenarios)+2)
+	for _, sc := range scenarios {
+		menu = append(menu, Pad(36, sc.name, "["+sc.board.name+"]"))
+	}
+	menu = append(menu, "", "Back")
+
+	active := 0
+	for {
+		res := RunMenu(title, menu, active)
+		if res == MENU_ESCAPE || res == len(menu)-1 {
+			return
+		}
+		RunGame(scenarios[res].NewGame)
+		active = res
+	}
+}
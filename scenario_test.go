@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+
+func playScenario(t *testing.T, s string) *GameState {
+	sc, err := ReadScenario(s, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := sc.NewGame()
+	for n := 0; n < int(sc.duration) && g.end_reason == nil; n++ {
+		g.Tick()
+	}
+	return g
+}
+
+func TestScenario(t *testing.T) {
+	g := playScenario(t, `
+		name: Straight
+		board: ATC Standard
+		rules: Default
+		duration: 20
+		plane A jet 0-9 0 5
+		plane B prop 1-8 4 5 10:30
+		event 1:30 Radar check
+	`)
+	if g.end_reason == nil || !g.end_reason.success {
+		t.Error("straight flights should succeed:", g.end_reason)
+	}
+	if b := g.FindPlane('B'); b.typ != &PLANE_TYPE_PROP || b.Direction != DIR_S || b.initial_height != 5 {
+		t.Error("wrong plane", b)
+	}
+
+	g = playScenario(t, `
+		duration: 16
+		plane A J 0-9 0 5
+		plane B J 9-0 0:15 5
+	`)
+	if g.end_reason == nil || g.end_reason.message != "Conflict" {
+		t.Error("head-on flights should conflict:", g.end_reason)
+	}
+}
+
+func TestScenarioPlanes(t *testing.T) {
+	sc, err := ReadScenario("plane A blackbird 0-9 0 10\nplane B jet 0-9-NE 1 9", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := sc.planes[0], sc.planes[1]; a.height != 10 || b.route.Direction != DIR_NE {
+		t.Error(a, b)
+	}
+}
+
+func TestInvalidScenario(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"duration: 16",
+		"plane A jet 0-9 0 5\nplane A jet 9-0 1 5",
+		"plane a jet 0-9 0 5",
+		"plane A glider 0-9 0 5",
+		"plane A jet 0-Q 0 5",
+		"plane A jet 0-1 0 5",
+		"plane A jet 0-1-XX 0 5",
+		"plane A jet 0-9 1:75 5",
+		"plane A jet 0-9 0 12",
+		"duration: 10\nplane A jet 0-9 10 5",
+		"plane A jet 0-9 0 5\nboard: Crossways",
+		"board: missing.board\nplane A jet 0-9 0 5",
+		"rules: Unknown\nplane A jet 0-9 0 5",
+		"plane A jet 0-9 0 5\nevent 3",
+		"plane A jet 0-9 0 5\nunknown",
+		"plane A jet 0-9 0 5\nevent 3 emergency",
+		"plane A blackbird 0-% 0 5",
+		"plane A blackbird %-0 0",
+		"plane A jet %-% 0",
+		"rules: ATC original\nplane A heli 0-9 0 5",
+		"plane A jet 0-9 0 5\nrules: ATC original",
+		"plane A jet 0-9 0 10",
+		"plane A jet 0-9 0 0",
+		"plane A jet 0-9-W 0 5",
+		"plane A jet %-9-N 0",
+	} {
+		if _, err := ReadScenario(s, "."); err == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+}
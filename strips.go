@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+const STRIP_WIDTH = 22
+
+// ticks until the plane reaches its exit; -1 if done
+func (p Plane) ExpectedArrival(clock Ticks) Ticks {
+	if p.IsDone() {
+		return -1
+	}
+
+	from := p.Position
+	var wait Ticks
+	if p.state == StatePending {
+		from = p.entry.Position
+		wait = Ticks(Max(0, int(clock-p.start-p.typ.ticks_pending)))
+	}
+
+	cadence := p.Cadence()
+	moves := from.Distance(p.exit.Position)
+	return wait + Ticks(moves)*cadence.ticks_per_move/Ticks(cadence.moves_per_tick)
+}
+
+// planes with a flight strip in display order
+func (g *GameState) Strips() []*Plane {
+	planes := make([]*Plane, 0, len(g.planes))
+	for _, p := range g.planes {
+		if p.IsActive() {
+			planes = append(planes, p)
+		}
+	}
+
+	if g.strips_by_arrival {
+		sort.SliceStable(planes, func(i, j int) bool {
+			return planes[i].ExpectedArrival(g.clock) < planes[j].ExpectedArrival(g.clock)
+		})
+	}
+	return planes
+}
+
+// move the selection n strips down
+func (g *GameState) SelectStrip(n int) {
+	strips := g.Strips()
+	if len(strips) == 0 {
+		return
+	}
+
+	pos := -1
+	for i, p := range strips {
+		if p == g.selected {
+			pos = i
+		}
+	}
+	if pos < 0 && n < 0 {
+		pos = 0
+	}
+	pos = (pos + n + len(strips)) % len(strips)
+	g.Select(strips[pos])
+}
+
+// status and pending instructions for the second strip line
+func (p Plane) strip_status(delayed []*Command) string {
+	var status []string
+
+	if p.HasEmergency() {
+		status = append(status, "!"+strings.ToUpper(p.emergency.String())+"!")
+	}
+
+	switch {
+	case p.state == StateIncoming:
+		status = append(status, "ENTERING")
+	case p.state == StateWaiting:
+		status = append(status, "READY")
+	case p.state == StateRolling:
+		status = append(status, "ROLLING")
+	case p.is_holding && p.hold.right:
+		status = append(status, fmt.Sprintf("HOLD R%d", p.hold.leg))
+	case p.is_holding:
+		status = append(status, fmt.Sprintf("HOLD L%d", p.hold.leg))
+	case p.hold_at_navaid && p.at_navaid != 0:
+		status = append(status, "HOLD@"+string(p.at_navaid))
+	case p.hold_at_navaid:
+		status = append(status, "HOLD@*")
+	case p.state == StateAproach:
+		status = append(status, "FINAL")
+	case p.clear_to_aproach != "" && p.at_navaid != 0:
+		status = append(status, fmt.Sprintf("CLR %s@%c", p.clear_to_aproach, p.at_navaid))
+	case p.clear_to_aproach != "":
+		status = append(status, "CLR "+p.clear_to_aproach)
+	case p.direct_to != nil:
+		status = append(status, "DCT "+p.direct_to.String())
+	}
+
+	for _, c := range p.conditions {
+		status = append(status, "{"+c.Instruction()+"}")
+	}
+	for _, c := range delayed {
+		if c.callsign == p.callsign {
+			status = append(status, fmt.Sprintf("%s%s", strings.Repeat(".", c.delayed), c.Instruction()))
+		}
+	}
+	return strings.Join(status, " ")
+}
+
+// two lines: flight data, status and pending instructions
+func (p Plane) Strip(delayed []*Command) (string, string) {
+	alt := fmt.Sprint(p.height)
+	if p.want_height != p.height && p.state != StateAproach {
+		alt += fmt.Sprintf(">%d", p.want_height)
+	}
+
+	heading := p.Direction.String()
+	if p.want_turn != 0 {
+		heading = p.Direction.Right(p.want_turn).String()
+	}
+
+	line := fmt.Sprintf("%c %c %s-%s %-3s %-2s F%d",
+		p.callsign, p.typ.mark, p.entry.name, p.exit.name,
+		alt, heading, p.fuel_left/Minutes)
+	return fit_strip(line), fit_strip("  " + p.strip_status(delayed))
+}
+
+// cut s to the strip width
+func fit_strip(s string) string {
+	runes := []rune(s)
+	if len(runes) > STRIP_WIDTH {
+		return string(runes[:STRIP_WIDTH-1]) + "~"
+	}
+	return s
+}
+
+// flight strips at the right of the board, wrapping into more columns
+// up to the right edge of the screen
+func DrawStrips(game *GameState, left, top, bottom int) {
+	termw, _ := termbox.Size()
+	col, row := left, top
+
+	for _, p := range game.Strips() {
+		if row+1 >= bottom {
+			row = top
+			col += STRIP_WIDTH + 2
+		}
+		if col+STRIP_WIDTH > termw {
+			break
+		}
+
+		color := theme.PlaneColor(p)
+		if p == game.selected {
+			color |= theme.selected
+		}
+
+		line, status := p.Strip(game.ci.delayed_commands)
+		printC(col, row, color, Pad(STRIP_WIDTH, line, ""))
+		printC(col, row+1, color, Pad(STRIP_WIDTH, status, ""))
+		row += 3
+	}
+}
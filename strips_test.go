@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestExpectedArrival(t *testing.T) {
+	g := testGame()
+	p := g.planes[0]
+	p.Position = Position{5, 10}
+	if eta := p.ExpectedArrival(g.clock); eta != 19 {
+		t.Error("jet:", eta)
+	}
+	p.typ = &PLANE_TYPE_PROP
+	if eta := p.ExpectedArrival(g.clock); eta != 38 {
+		t.Error("prop:", eta)
+	}
+
+	// waits until it enters, then flies from its entry
+	p = g.planes[1]
+	p.state = StatePending
+	p.start = 10 * Minutes
+	if eta := p.ExpectedArrival(g.clock); eta != 36+24 {
+		t.Error("pending:", eta)
+	}
+	p.state = StateDeparted
+	if eta := p.ExpectedArrival(g.clock); eta != -1 {
+		t.Error("departed:", eta)
+	}
+
+	g.planes[0].state = StateFlying
+	g.strips_by_arrival = true
+	if strips := g.Strips(); len(strips) != 1 || strips[0] != g.planes[0] {
+		t.Error("strips:", strips)
+	}
+}
+
+func TestStripWidth(t *testing.T) {
+	g := testGame()
+	p := g.planes[0]
+	p.entry = &EntryPoint{name: "KLAX"}
+	p.exit = &EntryPoint{name: "ÉCOLE-DE-L'AIR", is_airport: true}
+	p.clear_to_aproach = p.exit.name
+	p.emergency, p.emergency_left = EmergencyEngine, EMERGENCY_TIME
+	p.fuel_left = 15 * Minutes
+
+	line, status := p.Strip(nil)
+	for _, s := range []string{line, status} {
+		if n := len([]rune(s)); n != STRIP_WIDTH || []rune(s)[n-1] != '~' {
+			t.Errorf("%q should be cut to the strip width", s)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	termbox "github.com/nsf/termbox-go"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Theme struct {
+	name string
+
+	board     termbox.Attribute // grid dots
+	nofly     termbox.Attribute
+	fix       termbox.Attribute // entrypoints and navaids
+	plane     termbox.Attribute
+	levels    []termbox.Attribute // plane color by height; nil: plane color
+	selected  termbox.Attribute   // added to the last commanded plane
+	emergency termbox.Attribute
+	conflict  termbox.Attribute
+	done      termbox.Attribute // planes window
+	pending   termbox.Attribute // planes window
+
+	track         termbox.Attribute
+	track_warning termbox.Attribute
+}
+
+var THEME_CLASSIC = Theme{
+	name:          "Classic",
+	board:         termbox.ColorBlue,
+	nofly:         termbox.ColorBlue,
+	fix:           termbox.ColorDefault,
+	plane:         termbox.ColorDefault,
+	selected:      termbox.AttrBold,
+	emergency:     termbox.ColorMagenta,
+	conflict:      termbox.ColorRed,
+	done:          termbox.ColorGreen,
+	pending:       termbox.ColorBlue,
+	track:         termbox.ColorCyan,
+	track_warning: termbox.ColorRed,
+}
+
+var THEME_ALTITUDE = Theme{
+	name:  "Altitude",
+	board: termbox.ColorBlue,
+	nofly: termbox.ColorBlue,
+	fix:   termbox.ColorDefault,
+	plane: termbox.ColorDefault,
+	levels: []termbox.Attribute{
+		termbox.ColorDefault,
+		termbox.ColorYellow,
+		termbox.ColorGreen,
+		termbox.ColorCyan,
+		termbox.ColorDefault | termbox.AttrBold,
+		termbox.ColorWhite | termbox.AttrBold,
+	},
+	selected:      termbox.AttrReverse,
+	emergency:     termbox.ColorMagenta,
+	conflict:      termbox.ColorRed,
+	done:          termbox.ColorGreen,
+	pending:       termbox.ColorBlue,
+	track:         termbox.ColorCyan,
+	track_warning: termbox.ColorRed,
+}
+
+var THEME_HIGH_CONTRAST = Theme{
+	name:          "High Contrast",
+	board:         termbox.ColorDefault,
+	nofly:         termbox.ColorWhite | termbox.AttrBold,
+	fix:           termbox.ColorYellow | termbox.AttrBold,
+	plane:         termbox.ColorWhite | termbox.AttrBold,
+	selected:      termbox.AttrReverse,
+	emergency:     termbox.ColorMagenta | termbox.AttrBold,
+	conflict:      termbox.ColorRed | termbox.AttrBold | termbox.AttrReverse,
+	done:          termbox.ColorGreen | termbox.AttrBold,
+	pending:       termbox.ColorDefault,
+	track:         termbox.ColorCyan | termbox.AttrBold,
+	track_warning: termbox.ColorRed | termbox.AttrBold,
+}
+
+// attributes only
+var THEME_MONOCHROME = Theme{
+	name:          "Monochrome",
+	board:         termbox.ColorDefault,
+	nofly:         termbox.AttrBold,
+	fix:           termbox.AttrBold,
+	plane:         termbox.ColorDefault,
+	selected:      termbox.AttrReverse,
+	emergency:     termbox.AttrBold | termbox.AttrUnderline,
+	conflict:      termbox.AttrBold | termbox.AttrReverse,
+	done:          termbox.AttrBold,
+	pending:       termbox.AttrUnderline,
+	track:         termbox.ColorDefault,
+	track_warning: termbox.AttrBold | termbox.AttrReverse,
+}
+
+var THEMES = []*Theme{
+	&THEME_CLASSIC,
+	&THEME_ALTITUDE,
+	&THEME_HIGH_CONTRAST,
+	&THEME_MONOCHROME,
+}
+
+// active theme
+var theme = &THEME_CLASSIC
+
+// draw predicted tracks
+var show_tracks = true
+
+func (t *Theme) PlaneColor(p *Plane) termbox.Attribute {
+	switch {
+	case p.HasEmergency():
+		return t.emergency
+	case t.levels != nil:
+		return t.levels[Min(Max(p.height, 0), len(t.levels)-1)]
+	default:
+		return t.plane
+	}
+}
+
+func FindTheme(name string) *Theme {
+	for _, t := range THEMES {
+		if strings.EqualFold(t.name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// theme after t in THEMES
+func NextTheme(t *Theme) *Theme {
+	for n, other := range THEMES {
+		if other == t {
+			return THEMES[(n+1)%len(THEMES)]
+		}
+	}
+	return THEMES[0]
+}
+
+func SettingsFile() string {
+	return ConfigFile("settings.conf")
+}
+
+// read settings: lines "<setting> = <value>"
+func ReadSettings(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line_nr := 1; scanner.Scan(); line_nr += 1 {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s:%d: expected <setting> = <value>", filename, line_nr)
+		}
+		value := strings.TrimSpace(parts[1])
+
+		switch strings.TrimSpace(parts[0]) {
+		case "theme":
+			if theme = FindTheme(value); theme == nil {
+				theme = &THEME_CLASSIC
+				return fmt.Errorf("%s:%d: unknown theme %q", filename, line_nr, value)
+			}
+		case "tracks":
+			show_tracks = value == "on"
+		default:
+			return fmt.Errorf("%s:%d: unknown setting %q", filename, line_nr, parts[0])
+		}
+	}
+	return scanner.Err()
+}
+
+func WriteSettings(filename string) error {
+	if filename == "" {
+		return fmt.Errorf("no config directory")
+	}
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	tracks := "off"
+	if show_tracks {
+		tracks = "on"
+	}
+	settings := fmt.Sprintf("theme = %s\ntracks = %s\n", theme.name, tracks)
+	return os.WriteFile(filename, []byte(settings), 0644)
+}
@@ -0,0 +1,69 @@
+package main
+
+const (
+	TRACK_TICKS          = 1 * Minutes // projection of all flying planes
+	TRACK_TICKS_SELECTED = 3 * Minutes // projection of the selected plane
+)
+
+// projected positions of a plane
+type Track struct {
+	positions []Position
+	warning   bool // projection enters a nofly area or leaves the board away from the exit
+}
+
+// project the plane's movement for the given ticks without changing it
+func (p Plane) Predict(board *Board, ticks Ticks) Track {
+	var track Track
+
+	cadence := p.Cadence()
+	moves := int(ticks) * cadence.moves_per_tick / int(cadence.ticks_per_move)
+
+	for n := 0; n < moves && !p.is_hoovering; n += 1 {
+		if p.direct_to != nil {
+			p.SteerTowards(p.direct_to.Position)
+		}
+		if p.is_holding {
+			p.HoldStep()
+		}
+
+		next_pos := p.Position.Move(p.Direction, 1)
+		if !board.Contains(next_pos) {
+			track.warning = p.Position != p.exit.Position
+			return track
+		}
+		if p.state == StateAproach && board.GetEntryPoint(next_pos) == p.exit {
+			return track
+		}
+		if !p.typ.can_enter_nofly {
+			for _, nf := range board.nofly {
+				if nf == next_pos {
+					track.positions = append(track.positions, next_pos)
+					track.warning = true
+					return track
+				}
+			}
+		}
+
+		p.Position = next_pos
+		p.ApplyWants()
+		track.positions = append(track.positions, p.Position)
+
+		if p.direct_to != nil && p.ReachedFix() {
+			p.direct_to = nil
+			p.want_turn = 0
+		}
+
+		navaid := board.GetNavaid(p.Position)
+		if navaid != nil && (p.at_navaid == 0 || p.at_navaid == navaid.name) {
+			if p.hold_at_navaid && !p.is_holding {
+				p.is_holding = true
+				p.hold_step = 0
+			}
+			if ep, ok := board.entrypoints[p.clear_to_aproach]; ok {
+				p.Direction = ep.Direction
+				p.is_holding = false
+			}
+		}
+	}
+	return track
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestPredict(t *testing.T) {
+	g := testGame()
+	p := g.planes[0]
+
+	track := p.Predict(g.board, 4)
+	if len(track.positions) != 4 || track.positions[3] != (Position{9, 5}) || track.warning {
+		t.Error("straight:", track)
+	}
+
+	// a full right pattern ends where it started
+	p.is_holding = true
+	p.hold = Hold{right: true}
+	track = p.Predict(g.board, 8)
+	if len(track.positions) != 8 || track.positions[7] != p.Position || track.warning {
+		t.Error("hold:", track)
+	}
+	if p.Position != (Position{5, 5}) || p.Direction != DIR_E || p.hold_step != 0 {
+		t.Error("predict changed the plane", p)
+	}
+
+	// a plane entering a hold at a navaid stays near it
+	p.is_holding = false
+	p.Position = Position{3, 10}
+	p.hold_at_navaid = true
+	for _, pos := range p.Predict(g.board, 12).positions {
+		if pos.Distance(Position{5, 10}) > 3 {
+			t.Error("left the hold at", pos)
+		}
+	}
+}
+
+func TestPredictWarning(t *testing.T) {
+	g := testGame()
+	p := g.planes[0]
+
+	// leaves the board away from its exit
+	p.Position = Position{22, 5}
+	track := p.Predict(g.board, 4)
+	if len(track.positions) != 2 || !track.warning {
+		t.Error("boundary:", track)
+	}
+
+	// the exit itself is fine
+	p.Position = Position{22, 10}
+	if track := p.Predict(g.board, 4); track.warning {
+		t.Error("exit:", track)
+	}
+
+	b := *g.board
+	b.nofly = []Position{{8, 5}}
+	p.Position = Position{5, 5}
+	track = p.Predict(&b, 4)
+	if len(track.positions) != 3 || track.positions[2] != (Position{8, 5}) || !track.warning {
+		t.Error("nofly:", track)
+	}
+}
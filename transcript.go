@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const CONTROLLER = "ATC"
+
+type TranscriptEntry struct {
+	clock   Ticks
+	speaker string // CONTROLLER, callsign or empty for game events
+	message string
+}
+
+func (e TranscriptEntry) String() string {
+	return fmt.Sprintf("%s %-3s %s", e.clock, e.speaker, e.message)
+}
+
+func (g *GameState) Record(speaker string, message string) {
+	g.transcript = append(g.transcript, TranscriptEntry{
+		clock:   g.clock,
+		speaker: speaker,
+		message: message,
+	})
+}
+
+// record and speak a pilot message
+func (g *GameState) RecordPlane(p *Plane, message string) {
+	g.Record(string(p.callsign), message)
+	g.Voice().Say(p.callsign, message)
+}
+
+// voice of the game; silent if none is set
+func (g *GameState) Voice() Voice {
+	if g.voice == nil {
+		return NullVoice{}
+	}
+	return g.voice
+}
+
+// record changes of the plane state that happen without a command
+func (g *GameState) record_state(p *Plane) {
+	switch p.state {
+	case StateIncoming:
+		g.RecordPlane(p, fmt.Sprintf("entering at %s, level %d", p.entry.name, p.height))
+	case StateWaiting:
+		g.RecordPlane(p, fmt.Sprintf("ready for takeoff at %s", p.entry.name))
+	case StateLanded:
+		g.RecordPlane(p, fmt.Sprintf("landed at %s", p.exit.name))
+	case StateDeparted:
+		g.RecordPlane(p, fmt.Sprintf("departed at %s", p.exit.name))
+	}
+}
+
+func (g *GameState) WriteTranscript(filename string) error {
+	lines := make([]string, 0, len(g.transcript)+2)
+	lines = append(lines,
+		fmt.Sprintf("ATC transcript -- %s / %s / seed %d", g.board.name, g.rules.name, g.seed),
+		"")
+	for _, e := range g.transcript {
+		lines = append(lines, e.String())
+	}
+	return os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	termbox "github.com/nsf/termbox-go"
+)
+
+const TUTORIAL_DURATION = 20 * Minutes
+
+var (
+	TUTORIAL_BOARD = ParseBoard("Tutorial", `
+        ....................
+        ....................
+        ....................
+        0.......+%....N....1
+        ....................
+        ....................
+        .......=+...........
+        ....................
+        .........2..........
+    `, `
+        1: 0-1-E 1-0-W 2-1-N %-0-W =-1-E 1-%-W
+    `)
+
+	TUTORIAL_RULES = GameRules{
+		name: "Tutorial",
+
+		last_plane_start: 0,
+
+		skip_to_next_tick: true,
+		delayed_commands:  true,
+
+		have_jet:       true,
+		have_prop:      true,
+		have_heli:      true,
+		have_blackbird: false,
+
+		show_pending_planes: false,
+		emergencies:         false,
+	}
+)
+
+// a scripted scenario on the tutorial board
+type Lesson struct {
+	name   string
+	intro  []string
+	planes []LessonPlane
+	steps  []LessonStep
+}
+
+type LessonPlane struct {
+	callsign    rune
+	typ         *PlaneType
+	entry, exit string
+	dir         Direction
+	height      int
+}
+
+// the learner types command (clock stopped) or the game runs until done
+type LessonStep struct {
+	text    string
+	command string // expected command with the default keys
+	ticks   Ticks  // wait at least this long
+	done    func(g *GameState) bool
+	explain string // once the step is complete
+}
+
+var LESSONS = []*Lesson{
+	&Lesson{
+		name: "Takeoff",
+		intro: []string{
+			"Planes waiting at an airport take off when they",
+			"are cleared to climb. They leave the area at",
+			"their exit at level 5.",
+		},
+		planes: []LessonPlane{
+			{callsign: 'A', typ: &PLANE_TYPE_JET, entry: "%", exit: "0", dir: DIR_W},
+		},
+		steps: []LessonStep{
+			{
+				text:    "Jet A waits at airport %. Clear it to climb to level 5.",
+				command: "AA5",
+				explain: "A rolls down the runway, then climbs one level per move.",
+			},
+			{
+				text:    "Watch A climb and leave the area at exit 0.",
+				done:    plane_done('A'),
+				explain: "A left the area at its exit at level 5.",
+			},
+		},
+	},
+	&Lesson{
+		name: "Approach",
+		intro: []string{
+			"Planes for an airport turn into the runway",
+			"direction at a navaid. % and = clear a plane",
+			"for airport % or =; T<airport> works for all.",
+			"Altitude 0 starts the approach.",
+		},
+		planes: []LessonPlane{
+			{callsign: 'B', typ: &PLANE_TYPE_JET, entry: "1", exit: "%", dir: DIR_W, height: 3},
+		},
+		steps: []LessonStep{
+			{
+				text:    "Jet B is inbound to %. Clear it to turn towards % at the next navaid.",
+				command: "B%",
+				explain: "B turns into the runway direction at navaid N.",
+			},
+			{
+				text:    "Wait until B passes navaid N.",
+				done:    func(g *GameState) bool { return g.FindPlane('B').x < 14 },
+				explain: "B is lined up with the runway.",
+			},
+			{
+				text:    "Start the approach: descend to level 0.",
+				command: "BA0",
+				explain: "B descends one level per move.",
+			},
+			{
+				text:    "B lands if it reaches % at level 0.",
+				done:    plane_done('B'),
+				explain: "B landed.",
+			},
+		},
+	},
+	&Lesson{
+		name: "Holding",
+		intro: []string{
+			"Holding keeps a plane circling over a navaid",
+			"until the runway is free. Planes for an airport",
+			"hold at a navaid on their own unless cleared.",
+		},
+		planes: []LessonPlane{
+			{callsign: 'C', typ: &PLANE_TYPE_PROP, entry: "1", exit: "%", dir: DIR_W, height: 2},
+		},
+		steps: []LessonStep{
+			{
+				text:    "Prop C is inbound to %. Hold it at the next navaid.",
+				command: "CH",
+				explain: "C will circle at the next navaid.",
+			},
+			{
+				text:    "Wait for C to enter the hold at N.",
+				done:    func(g *GameState) bool { return g.FindPlane('C').is_holding },
+				explain: "C circles over N.",
+			},
+			{
+				text:    "The runway is free. Clear C to turn towards %.",
+				command: "C%",
+				explain: "C leaves the hold when it passes N again.",
+			},
+			{
+				text: "Wait until C leaves the hold.",
+				done: func(g *GameState) bool {
+					p := g.FindPlane('C')
+					return !p.is_holding && p.y == 3 && p.x < 14
+				},
+				explain: "C is lined up with the runway.",
+			},
+			{
+				text:    "Start the approach.",
+				command: "CA0",
+				explain: "C descends towards %.",
+			},
+			{
+				text:    "Wait for C to land.",
+				done:    plane_done('C'),
+				explain: "C landed.",
+			},
+		},
+	},
+	&Lesson{
+		name: "Helicopters",
+		intro: []string{
+			"Helicopters turn on the spot and can hover.",
+			"The keep command toggles hovering.",
+		},
+		planes: []LessonPlane{
+			{callsign: 'H', typ: &PLANE_TYPE_HELI, entry: "2", exit: "1", dir: DIR_N, height: 3},
+		},
+		steps: []LessonStep{
+			{
+				text:    "Stop helicopter H in place.",
+				command: "HK",
+				explain: "H hovers.",
+			},
+			{
+				text:    "H hovers for a minute.",
+				ticks:   1 * Minutes,
+				explain: "H is still at the same position.",
+			},
+			{
+				text:    "Continue the flight.",
+				command: "HK",
+				explain: "H flies on.",
+			},
+			{
+				text:    "Climb to level 5 for the exit.",
+				command: "HA5",
+				explain: "H climbs.",
+			},
+			{
+				text:    "Fly direct to exit 1.",
+				command: "HD1",
+				explain: "H steers towards exit 1 on its own.",
+			},
+			{
+				text:    "Wait for H to leave the area.",
+				done:    plane_done('H'),
+				explain: "H left the area.",
+			},
+		},
+	},
+	&Lesson{
+		name: "Delayed commands",
+		intro: []string{
+			"Each dot before a command delays it by one tick.",
+			"Pending commands are listed with # and can be",
+			"edited or cancelled there.",
+		},
+		planes: []LessonPlane{
+			{callsign: 'E', typ: &PLANE_TYPE_JET, entry: "0", exit: "1", dir: DIR_E, height: 7},
+		},
+		steps: []LessonStep{
+			{
+				text:    "Descend jet E to level 5, two ticks from now.",
+				command: "..EA5",
+				explain: "The command waits in the list of delayed commands.",
+			},
+			{
+				text:    "Wait until the command is applied.",
+				done:    func(g *GameState) bool { return g.FindPlane('E').want_height == 5 },
+				explain: "E descends to level 5.",
+			},
+			{
+				text:    "Wait for E to leave the area.",
+				done:    plane_done('E'),
+				explain: "E left the area.",
+			},
+		},
+	},
+}
+
+func plane_done(callsign rune) func(g *GameState) bool {
+	return func(g *GameState) bool {
+		return g.FindPlane(callsign).IsDone()
+	}
+}
+
+// progress of a lesson during the game
+type Tutor struct {
+	lesson  *Lesson
+	step    int
+	started Ticks    // clock at the start of the step
+	expect  *Command // for command steps
+	message string   // explanation of the last step or a hint
+}
+
+func (l *Lesson) NewGame() *GameState {
+	diff := &Difficulty{name: "Tutorial", duration: TUTORIAL_DURATION}
+	game := NewGame(&TUTORIAL_RULES, TUTORIAL_BOARD, diff, 0)
+
+	for _, lp := range l.planes {
+		entry := TUTORIAL_BOARD.entrypoints[lp.entry]
+		exit := TUTORIAL_BOARD.entrypoints[lp.exit]
+		p := NewPlane(lp.typ, entry, exit, lp.dir, TUTORIAL_DURATION-1, lp.height)
+		p.callsign = lp.callsign
+		game.planes = append(game.planes, p)
+	}
+
+	game.tutor = &Tutor{lesson: l}
+	game.tutor.begin(game)
+	return game
+}
+
+// start the current step
+func (t *Tutor) begin(g *GameState) {
+	t.started = g.clock
+	t.expect = nil
+	if t.step >= len(t.lesson.steps) {
+		return
+	}
+
+	if s := t.lesson.steps[t.step]; s.command != "" {
+		// lessons are written with the default keys
+		ci := CommandInterpreter{board: g.board, keys: &DEFAULT_KEY_BINDINGS}
+		t.expect, _ = ci.parse(s.command, true)
+		if t.expect == nil || !t.expect.valid {
+			panic("invalid lesson command: " + s.command)
+		}
+	}
+}
+
+// advance through the steps that are done and stop the clock for commands
+func (t *Tutor) Update(g *GameState) {
+	for t.step < len(t.lesson.steps) {
+		s := t.lesson.steps[t.step]
+		if t.expect != nil {
+			p := g.FindPlane(t.expect.callsign)
+			g.paused = p != nil && p.AcceptsCommands()
+			return
+		}
+		if t.started-g.clock < s.ticks || s.done != nil && !s.done(g) {
+			g.paused = false
+			return
+		}
+		t.next(g)
+	}
+	g.paused = false
+}
+
+// true while the lesson waits for the expected command
+func (t *Tutor) Waiting() bool {
+	return t != nil && t.expect != nil
+}
+
+func (t *Tutor) next(g *GameState) {
+	t.message = t.lesson.steps[t.step].explain
+	t.step += 1
+	t.begin(g)
+}
+
+// whether cmd is the expected one; explains otherwise
+func (t *Tutor) Accept(cmd *Command) bool {
+	if t.expect == nil {
+		t.message = "Not now, watch the planes."
+		return false
+	}
+	if !cmd.valid || cmd.String() != t.expect.String() {
+		t.message = "Try again: type " + t.expect.String()
+		return false
+	}
+	return true
+}
+
+// the expected command was accepted by the plane
+func (t *Tutor) CommandDone(g *GameState) {
+	t.next(g)
+	t.Update(g)
+}
+
+func (t *Tutor) Done() bool {
+	return t.step >= len(t.lesson.steps)
+}
+
+func DrawTutor(g *GameState, v Viewport) {
+	t := g.tutor
+	x, y := v.left, v.top+v.rows+STATUS_LINES
+
+	printC(x, y, theme.done, t.message)
+	switch {
+	case t.Done():
+		print(x, y+1, "Lesson complete.")
+	case t.expect != nil:
+		s := t.lesson.steps[t.step]
+		printC(x, y+1, termbox.AttrBold, s.text)
+		print(x, y+2, "Type: ", t.expect.String())
+	default:
+		print(x, y+1, t.lesson.steps[t.step].text)
+	}
+}
+
+func TutorialMenu() {
+	active := 0
+	for {
+		menu := make([]string, 0, len(LESSONS)+2)
+		for nr, l := range LESSONS {
+			menu = append(menu, fmt.Sprintf("%d. %s", nr+1, l.name))
+		}
+		menu = append(menu, "", "Back")
+
+		res := RunMenu("Tutorial", menu, active)
+		if res == MENU_ESCAPE || res == len(menu)-1 {
+			return
+		}
+
+		l := LESSONS[res]
+		if RunMessage("Lesson: "+l.name, "Enter: start  Esc: back", l.intro) {
+			game := RunGame(l.NewGame)
+			RunLessonResult(game)
+		}
+		active = Min(res+1, len(LESSONS)-1)
+	}
+}
+
+func RunLessonResult(game *GameState) {
+	switch {
+	case game.end_reason == nil:
+		return
+	case game.end_reason.success:
+		RunMessage("Lesson complete", "Press Enter", []string{game.tutor.lesson.name + " done."})
+	default:
+		RunMessage("Lesson failed", "Press Enter", []string{
+			game.end_reason.message,
+			"Restart the lesson and follow the instructions.",
+		})
+	}
+}
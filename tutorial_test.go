@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// play every lesson with the expected commands
+func TestLessons(t *testing.T) {
+	if err := TUTORIAL_BOARD.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range LESSONS {
+		g := l.NewGame()
+		for n := 0; n < int(TUTORIAL_DURATION) && g.end_reason == nil; n++ {
+			if step := g.tutor.step; g.tutor.expect != nil && g.paused {
+				g.ci.set_buffer(l.steps[step].command)
+				g.Submit()
+				if g.tutor.step == step {
+					t.Fatal(l.name, g.ci.last, g.ci.reply)
+				}
+			}
+			g.Tick()
+		}
+
+		if g.end_reason == nil || !g.end_reason.success || !g.tutor.Done() {
+			t.Error(l.name, g.end_reason, g.tutor.step, g.tutor.message)
+		}
+	}
+}
+
+func TestTutorRejects(t *testing.T) {
+	g := LESSONS[0].NewGame()
+	for !g.paused {
+		g.Tick()
+	}
+
+	g.ci.set_buffer("AA4")
+	g.Submit()
+	if g.tutor.step != 0 || g.FindPlane('A').want_height != 0 {
+		t.Error("wrong command applied", g.ci.reply)
+	}
+
+	g.ci.set_buffer("AA5")
+	g.Submit()
+	if g.tutor.step != 1 || g.paused {
+		t.Error("expected command not accepted", g.ci.reply, g.tutor.message)
+	}
+}
+
+// lessons use the default keys; the player types the remapped ones
+func TestTutorRemappedKeys(t *testing.T) {
+	filename := t.TempDir() + "/keys.conf"
+	if err := os.WriteFile(filename, []byte("altitude = U\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kb, err := ReadKeyBindings(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { key_bindings = &DEFAULT_KEY_BINDINGS }()
+	key_bindings = kb
+
+	g := LESSONS[0].NewGame()
+	for !g.paused {
+		g.Tick()
+	}
+	if key_bindings != kb || !g.tutor.Waiting() {
+		t.Fatal("lesson changed the key bindings or does not wait")
+	}
+
+	g.ci.set_buffer("AU5")
+	g.Submit()
+	if g.tutor.step != 1 || g.tutor.Waiting() {
+		t.Error("remapped command not accepted", g.ci.reply)
+	}
+}
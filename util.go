@@ -3,6 +3,8 @@ package main
 import (
 	crand "crypto/rand"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -27,6 +29,15 @@ func Min(a int, b int) int {
 	return b
 }
 
+// file in the atc config directory; "" if there is none
+func ConfigFile(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "atc", name)
+}
+
 func RandSeed() int64 {
 	rbuf := make([]byte, 4)
 	_, err := crand.Read(rbuf)
@@ -110,6 +121,16 @@ func FirstRune(s string) rune {
 
 const PAD_SPACE = "                                                              "
 
+// next larger value for cycling through a menu setting; wraps around
+func NextValue(values []int, v int) int {
+	for _, value := range values {
+		if value > v {
+			return value
+		}
+	}
+	return values[0]
+}
+
 func Pad(width int, left string, right string) string {
 	pad := Max(0, width-len(left)-len(right))
 	return left + PAD_SPACE[0:pad] + right
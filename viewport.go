@@ -0,0 +1,186 @@
+package main
+
+import (
+	termbox "github.com/nsf/termbox-go"
+)
+
+const (
+	STATUS_LINES  = 3 // below the radar
+	MINIMAP_WIDTH = STRIP_WIDTH
+)
+
+// scroll position and zoom of the radar
+type View struct {
+	x, y    int  // board cell at the top left
+	compact bool // one character per cell
+	follow  bool // keep the selected plane in the middle
+}
+
+// part of the board visible on the screen
+type Viewport struct {
+	left, top  int // screen position
+	cols, rows int // visible cells
+	cell       int // characters per cell
+	x, y       int // board cell at the top left
+}
+
+// scaled down board for boards larger than the screen
+type Minimap struct {
+	left, top     int
+	width, height int
+	scale         int // board cells per character
+}
+
+// viewport for the current terminal size
+func (g *GameState) Viewport() Viewport {
+	termw, termh := termbox.Size()
+	return g.ViewportFor(termw, termh)
+}
+
+// viewport for a terminal size. Follows the selected plane and keeps the
+// board on the screen without changing the stored view.
+func (g *GameState) ViewportFor(termw, termh int) Viewport {
+	board := g.board
+
+	v := Viewport{cell: 2}
+	if g.view.compact {
+		v.cell = 1
+	}
+
+	v.cols = Min(board.width, Max(1, (termw-STRIP_WIDTH-2)/v.cell))
+	v.rows = Min(board.height, Max(1, termh-STATUS_LINES))
+
+	width := v.cols*v.cell + 2 + STRIP_WIDTH
+	v.left = Max(0, (termw-width)/2)
+	v.top = Max(0, (termh-v.rows-2)/2)
+
+	x, y := g.view.x, g.view.y
+	if g.view.follow && g.selected != nil && g.selected.IsFlying() {
+		x = g.selected.x - v.cols/2
+		y = g.selected.y - v.rows/2
+	}
+	v.x, v.y = v.clamp(board, x, y)
+	return v
+}
+
+// top left cell that keeps the board on the screen
+func (v Viewport) clamp(board *Board, x, y int) (int, int) {
+	return Max(0, Min(x, board.width-v.cols)), Max(0, Min(y, board.height-v.rows))
+}
+
+// move the view to the top left cell x, y; stops following
+func (g *GameState) scroll_to(v Viewport, x, y int) {
+	g.view.follow = false
+	g.view.x, g.view.y = v.clamp(g.board, x, y)
+}
+
+// scroll by a quarter of the visible area
+func (g *GameState) Scroll(dx, dy int) {
+	v := g.Viewport()
+	g.scroll_to(v, v.x+dx*Max(1, v.cols/4), v.y+dy*Max(1, v.rows/4))
+}
+
+// center the view on pos
+func (g *GameState) CenterView(pos Position) {
+	v := g.Viewport()
+	g.scroll_to(v, pos.x-v.cols/2, pos.y-v.rows/2)
+}
+
+// switch between one and two characters per cell
+func (g *GameState) Zoom() {
+	g.view.compact = !g.view.compact
+	v := g.Viewport()
+	g.view.x, g.view.y = v.x, v.y
+}
+
+// follow the selected plane or stay where it is
+func (g *GameState) ToggleFollow() {
+	v := g.Viewport()
+	g.view.x, g.view.y = v.x, v.y
+	g.view.follow = !g.view.follow
+}
+
+func (v Viewport) Fits(board *Board) bool {
+	return v.cols == board.width && v.rows == board.height
+}
+
+// screen position of a cell; false if it is not visible
+func (v Viewport) Screen(pos Position) (int, int, bool) {
+	x, y := pos.x-v.x, pos.y-v.y
+	visible := x >= 0 && x < v.cols && y >= 0 && y < v.rows
+	return v.left + x*v.cell, v.top + y, visible
+}
+
+// cell at a screen position; false if outside the viewport
+func (v Viewport) Cell(sx, sy int) (Position, bool) {
+	if sx < v.left || sy < v.top {
+		return Position{}, false
+	}
+	x, y := (sx-v.left)/v.cell, sy-v.top
+	return Position{v.x + x, v.y + y}, x < v.cols && y < v.rows
+}
+
+// print s at a cell, cut to the cell width
+func (v Viewport) Print(pos Position, color termbox.Attribute, s string) {
+	x, y, visible := v.Screen(pos)
+	if !visible {
+		return
+	}
+	runes := []rune(s)
+	printC(x, y, color, string(runes[:Min(len(runes), v.cell)]))
+}
+
+// print a plane marker at a cell; in the compact view it covers the next
+// cell so the height stays visible
+func (v Viewport) PrintMarker(pos Position, color termbox.Attribute, s string) {
+	if x, y, visible := v.Screen(pos); visible {
+		printC(x, y, color, s)
+	}
+}
+
+// right column below the flight strips
+func (v Viewport) Minimap(board *Board) Minimap {
+	scale := Max((board.width+MINIMAP_WIDTH-1)/MINIMAP_WIDTH,
+		(board.height+v.rows/3-1)/Max(1, v.rows/3))
+	scale = Max(scale, 1)
+
+	m := Minimap{
+		width:  (board.width + scale - 1) / scale,
+		height: (board.height + scale - 1) / scale,
+		scale:  scale,
+	}
+	m.left = v.left + v.cols*v.cell + 2
+	m.top = v.top + v.rows - m.height
+	return m
+}
+
+// board cell at a screen position; false if outside the minimap
+func (m Minimap) Cell(sx, sy int) (Position, bool) {
+	x, y := sx-m.left, sy-m.top
+	inside := x >= 0 && x < m.width && y >= 0 && y < m.height
+	return Position{x*m.scale + m.scale/2, y*m.scale + m.scale/2}, inside
+}
+
+func DrawMinimap(game *GameState, v Viewport, m Minimap) {
+	for my := 0; my < m.height; my += 1 {
+		for mx := 0; mx < m.width; mx += 1 {
+			x, y := mx*m.scale, my*m.scale
+
+			color := theme.board
+			if x+m.scale > v.x && x < v.x+v.cols && y+m.scale > v.y && y < v.y+v.rows {
+				color = theme.selected
+			}
+			printC(m.left+mx, m.top+my, color, "·")
+		}
+	}
+
+	for _, nf := range game.board.nofly {
+		printC(m.left+nf.x/m.scale, m.top+nf.y/m.scale, theme.nofly, "X")
+	}
+
+	for _, p := range game.planes {
+		if p.IsFlying() {
+			printC(m.left+p.x/m.scale, m.top+p.y/m.scale, theme.PlaneColor(p), string(p.callsign))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestViewport(t *testing.T) {
+	g := testGame()
+	if v := g.ViewportFor(100, 40); !v.Fits(g.board) || v.cell != 2 {
+		t.Error("board should fit:", v)
+	}
+
+	// 10x10 cells of the 25x20 board
+	g.view.x, g.view.y = 100, 100
+	v := g.ViewportFor(44, 13)
+	if v.cols != 10 || v.rows != 10 || v.x != 15 || v.y != 10 {
+		t.Fatal("wrong viewport", v)
+	}
+	if g.view.x != 100 || g.view.y != 100 {
+		t.Error("viewport changed the view", g.view)
+	}
+
+	if x, y, ok := v.Screen(Position{17, 12}); !ok || x != v.left+4 || y != v.top+2 {
+		t.Error("screen", x, y, ok)
+	}
+	if _, _, ok := v.Screen(Position{14, 12}); ok {
+		t.Error("left of the view should be hidden")
+	}
+	for _, sx := range []int{v.left + 4, v.left + 5} {
+		if pos, ok := v.Cell(sx, v.top+2); !ok || pos != (Position{17, 12}) {
+			t.Error("cell", sx, pos, ok)
+		}
+	}
+	if _, ok := v.Cell(v.left+20, v.top); ok {
+		t.Error("right of the view should be outside")
+	}
+
+	g.scroll_to(v, -5, 100)
+	if g.view.x != 0 || g.view.y != 10 || g.view.follow {
+		t.Error("scroll should clamp", g.view)
+	}
+
+	g.selected = g.planes[0]
+	g.selected.Position = Position{20, 15}
+	g.view.follow = true
+	if v := g.ViewportFor(44, 13); v.x != 15 || v.y != 10 {
+		t.Error("follow", v)
+	}
+
+	g.view = View{compact: true}
+	if v := g.ViewportFor(44, 13); v.cell != 1 || v.cols != 20 {
+		t.Error("compact", v)
+	}
+}
+
+func TestMinimap(t *testing.T) {
+	g := testGame()
+	v := g.ViewportFor(44, 13)
+	m := v.Minimap(g.board)
+	if m.scale != 7 || m.width != 4 || m.height != 3 {
+		t.Fatal("wrong minimap", m)
+	}
+	if m.left != v.left+v.cols*v.cell+2 || m.top+m.height != v.top+v.rows {
+		t.Error("minimap should be below the strips", m)
+	}
+
+	if pos, ok := m.Cell(m.left, m.top); !ok || pos != (Position{3, 3}) {
+		t.Error("cell", pos, ok)
+	}
+	if pos, ok := m.Cell(m.left+3, m.top+2); !ok || pos != (Position{24, 17}) {
+		t.Error("cell", pos, ok)
+	}
+	if _, ok := m.Cell(m.left-1, m.top); ok {
+		t.Error("left of the minimap should be outside")
+	}
+}